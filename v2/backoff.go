@@ -0,0 +1,170 @@
+package gobayeux
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// BackoffPolicy determines how long to wait before retrying a request that
+// failed for a transient reason, such as a network error or a 5xx response
+// from the server.
+type BackoffPolicy interface {
+	// NextBackoff returns the delay before retry number attempt, starting
+	// at 1. previous is the delay returned for the prior attempt or zero
+	// for the first.
+	NextBackoff(attempt int, previous time.Duration) time.Duration
+	// MaxElapsedTime is how long to keep retrying since the first failure
+	// before giving up and surfacing the error. Zero means retry forever.
+	MaxElapsedTime() time.Duration
+}
+
+// DefaultBackoffInterval is the delay used in place of a zero, or negative,
+// ConstantBackoff.Interval, ExponentialBackoff.Initial or
+// DecorrelatedJitterBackoff.Base, which would otherwise retry without waiting
+const DefaultBackoffInterval = 500 * time.Millisecond
+
+// defaultAdviceBackoff spaces out the retries the server advises after
+// refusing a request when no BackoffPolicy is chosen, so that the default
+// interval of zero does not retry in a tight loop
+var defaultAdviceBackoff BackoffPolicy = ExponentialBackoff{Initial: DefaultBackoffInterval, Max: 30 * time.Second}
+
+// orDefaultInterval returns d unless it is not positive
+func orDefaultInterval(d time.Duration) time.Duration {
+	if d <= 0 {
+		return DefaultBackoffInterval
+	}
+	return d
+}
+
+// ConstantBackoff waits the same Interval before every retry
+type ConstantBackoff struct {
+	Interval   time.Duration
+	MaxElapsed time.Duration
+}
+
+// NextBackoff implements the BackoffPolicy interface
+func (b ConstantBackoff) NextBackoff(attempt int, previous time.Duration) time.Duration {
+	return orDefaultInterval(b.Interval)
+}
+
+// MaxElapsedTime implements the BackoffPolicy interface
+func (b ConstantBackoff) MaxElapsedTime() time.Duration {
+	return b.MaxElapsed
+}
+
+// ExponentialBackoff doubles the delay after every failed attempt, starting
+// with Initial and never exceeding Max. A zero Max means no limit.
+type ExponentialBackoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	MaxElapsed time.Duration
+}
+
+// NextBackoff implements the BackoffPolicy interface
+func (b ExponentialBackoff) NextBackoff(attempt int, previous time.Duration) time.Duration {
+	delay := orDefaultInterval(b.Initial)
+	for i := 1; i < attempt && delay < math.MaxInt64/2; i++ {
+		delay *= 2
+	}
+	if b.Max > 0 {
+		return min(delay, b.Max)
+	}
+	return delay
+}
+
+// MaxElapsedTime implements the BackoffPolicy interface
+func (b ExponentialBackoff) MaxElapsedTime() time.Duration {
+	return b.MaxElapsed
+}
+
+// DecorrelatedJitterBackoff picks a random delay between Base and three times
+// the previous delay, never exceeding Max. The randomness keeps many clients
+// from retrying in lockstep after a server outage. A zero Max means no limit.
+//
+// See also: https://aws.amazon.com/blogs/architecture/exponential-backoff-and-jitter/
+type DecorrelatedJitterBackoff struct {
+	Base       time.Duration
+	Max        time.Duration
+	MaxElapsed time.Duration
+}
+
+// NextBackoff implements the BackoffPolicy interface
+func (b DecorrelatedJitterBackoff) NextBackoff(attempt int, previous time.Duration) time.Duration {
+	base := orDefaultInterval(b.Base)
+	upper := max(previous*3, base)
+	delay := base
+	if upper > base {
+		delay += time.Duration(rand.Int63n(int64(upper - base)))
+	}
+	if b.Max > 0 {
+		return min(delay, b.Max)
+	}
+	return delay
+}
+
+// MaxElapsedTime implements the BackoffPolicy interface
+func (b DecorrelatedJitterBackoff) MaxElapsedTime() time.Duration {
+	return b.MaxElapsed
+}
+
+// retrier tracks consecutive failures of a request under a BackoffPolicy
+type retrier struct {
+	policy   BackoffPolicy
+	attempt  int
+	previous time.Duration
+	start    time.Time
+}
+
+func newRetrier(policy BackoffPolicy) *retrier {
+	return &retrier{policy: policy}
+}
+
+// next returns how long to wait before retrying and whether we should retry
+// at all
+func (r *retrier) next() (time.Duration, bool) {
+	if r.policy == nil {
+		return 0, false
+	}
+
+	if r.attempt == 0 {
+		r.start = time.Now()
+	}
+	if maxElapsed := r.policy.MaxElapsedTime(); maxElapsed > 0 && time.Since(r.start) >= maxElapsed {
+		return 0, false
+	}
+
+	r.attempt++
+	r.previous = r.policy.NextBackoff(r.attempt, r.previous)
+	return r.previous, true
+}
+
+// reset is called once the request succeeds
+func (r *retrier) reset() {
+	r.attempt = 0
+	r.previous = 0
+}
+
+// isTransient reports whether err is likely to go away if the request is
+// retried. ctx is the context the request was made with so that our own
+// cancellation is not mistaken for a timeout talking to the server.
+func isTransient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var badResponse BadResponseError
+	if errors.As(err, &badResponse) {
+		return badResponse.StatusCode >= http.StatusInternalServerError ||
+			badResponse.StatusCode == http.StatusTooManyRequests
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, ErrConnectionClosed) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
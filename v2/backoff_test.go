@@ -0,0 +1,121 @@
+package gobayeux
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoff_NextBackoff(t *testing.T) {
+	b := ExponentialBackoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	testCases := []struct {
+		attempt  int
+		expected time.Duration
+	}{
+		{1, 100 * time.Millisecond},
+		{2, 200 * time.Millisecond},
+		{3, 400 * time.Millisecond},
+		{4, 800 * time.Millisecond},
+		{5, time.Second},
+		{100, time.Second},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		if got := b.NextBackoff(tc.attempt, 0); got != tc.expected {
+			t.Errorf("attempt %d: expected %v but got %v", tc.attempt, tc.expected, got)
+		}
+	}
+}
+
+func TestDecorrelatedJitterBackoff_NextBackoff(t *testing.T) {
+	b := DecorrelatedJitterBackoff{Base: 10 * time.Millisecond, Max: time.Second}
+	var previous time.Duration
+	for attempt := 1; attempt <= 50; attempt++ {
+		delay := b.NextBackoff(attempt, previous)
+		if delay < b.Base || delay > b.Max {
+			t.Fatalf("attempt %d: delay %v outside [%v, %v]", attempt, delay, b.Base, b.Max)
+		}
+		if upper := max(previous*3, b.Base); delay > upper {
+			t.Fatalf("attempt %d: delay %v exceeds three times previous delay %v", attempt, delay, previous)
+		}
+		previous = delay
+	}
+}
+
+func TestBackoff_ZeroIntervalUsesDefault(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy BackoffPolicy
+	}{
+		{"constant", ConstantBackoff{}},
+		{"exponential", ExponentialBackoff{}},
+		{"decorrelated jitter", DecorrelatedJitterBackoff{Base: -time.Second}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.policy.NextBackoff(1, 0); got < DefaultBackoffInterval {
+				t.Errorf("expected to wait at least %v but got %v", DefaultBackoffInterval, got)
+			}
+		})
+	}
+}
+
+func TestRetrier(t *testing.T) {
+	t.Run("no policy", func(t *testing.T) {
+		if _, ok := newRetrier(nil).next(); ok {
+			t.Error("expected no retries without a policy")
+		}
+	})
+
+	t.Run("max elapsed time", func(t *testing.T) {
+		r := newRetrier(ConstantBackoff{Interval: time.Millisecond, MaxElapsed: 20 * time.Millisecond})
+		if delay, ok := r.next(); !ok || delay != time.Millisecond {
+			t.Fatalf("expected to retry after 1ms but got %v, %v", delay, ok)
+		}
+		time.Sleep(25 * time.Millisecond)
+		if _, ok := r.next(); ok {
+			t.Fatal("expected to give up after max elapsed time")
+		}
+
+		r.reset()
+		if _, ok := r.next(); !ok {
+			t.Fatal("expected to retry after reset")
+		}
+	})
+}
+
+func TestIsTransient(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testCases := []struct {
+		name     string
+		ctx      context.Context
+		err      error
+		expected bool
+	}{
+		{"server error", context.Background(), ConnectionFailedError{BadResponseError{StatusCode: http.StatusBadGateway}}, true},
+		{"too many requests", context.Background(), BadResponseError{StatusCode: http.StatusTooManyRequests}, true},
+		{"client error", context.Background(), HandshakeFailedError{BadResponseError{StatusCode: http.StatusBadRequest}}, false},
+		{"network error", context.Background(), ConnectionFailedError{&net.OpError{Op: "dial", Err: errors.New("refused")}}, true},
+		{"connection closed", context.Background(), ConnectionFailedError{ErrConnectionClosed}, true},
+		{"timeout", context.Background(), ConnectionFailedError{context.DeadlineExceeded}, true},
+		{"unsuccessful reply", context.Background(), ConnectionFailedError{ErrFailedToConnect}, false},
+		{"canceled", canceled, ConnectionFailedError{context.Canceled}, false},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isTransient(tc.ctx, tc.err); got != tc.expected {
+				t.Errorf("expected %v but got %v", tc.expected, got)
+			}
+		})
+	}
+}
@@ -1,10 +1,7 @@
 package gobayeux
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"io"
 	"net/http"
 	"net/http/cookiejar"
 	"net/url"
@@ -15,18 +12,24 @@ import (
 	"golang.org/x/net/publicsuffix"
 )
 
+// maxNetworkDelay is the time allowed, on top of the advised timeout, for a
+// /meta/connect request to travel to the server and back
+const maxNetworkDelay = 10 * time.Second
+
 // BayeuxClient is a way of acting as a client with a given Bayeux server
 type BayeuxClient struct {
-	stateMachine  *ConnectionStateMachine
-	client        *http.Client
-	serverAddress *url.URL
-	state         *clientState
-	exts          []MessageExtender
-	logger        Logger
+	stateMachine     *ConnectionStateMachine
+	client           *http.Client
+	state            *clientState
+	exts             extensionPipeline
+	logger           Logger
+	transports       []Transport
+	defaultTransport Transport
+	ids              IDGenerator
 }
 
 // NewBayeuxClient initializes a BayeuxClient for the user
-func NewBayeuxClient(client *http.Client, transport http.RoundTripper, serverAddress string, logger Logger) (*BayeuxClient, error) {
+func NewBayeuxClient(client *http.Client, roundTripper http.RoundTripper, serverAddress string, logger Logger) (*BayeuxClient, error) {
 	if client == nil {
 		jar, err := cookiejar.New(&cookiejar.Options{PublicSuffixList: publicsuffix.List})
 		if err != nil {
@@ -40,10 +43,10 @@ func NewBayeuxClient(client *http.Client, transport http.RoundTripper, serverAdd
 			Timeout:       http.DefaultClient.Timeout,
 		}
 	}
-	if transport == nil {
-		transport = http.DefaultTransport
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
 	}
-	client.Transport = transport
+	client.Transport = roundTripper
 
 	parsedAddress, err := url.Parse(serverAddress)
 	if err != nil {
@@ -54,17 +57,20 @@ func NewBayeuxClient(client *http.Client, transport http.RoundTripper, serverAdd
 		logger = newNullLogger()
 	}
 
+	longPolling := newLongPollingTransport(client, parsedAddress, logger)
 	return &BayeuxClient{
-		stateMachine:  NewConnectionStateMachine(),
-		client:        client,
-		serverAddress: parsedAddress,
-		state:         &clientState{},
-		logger:        logger,
+		stateMachine:     NewConnectionStateMachine(),
+		client:           client,
+		state:            &clientState{servers: []*url.URL{parsedAddress}},
+		logger:           logger,
+		transports:       []Transport{longPolling},
+		defaultTransport: longPolling,
+		ids:              &CounterIDGenerator{},
 	}, nil
 }
 
 // Handshake sends the handshake request to the Bayeux Server
-func (b *BayeuxClient) Handshake(ctx context.Context) ([]Message, error) {
+func (b *BayeuxClient) Handshake(ctx context.Context) (response []Message, err error) {
 	logger := b.logger.WithField("at", "handshake")
 	start := time.Now()
 	logger.Debug("starting")
@@ -72,26 +78,32 @@ func (b *BayeuxClient) Handshake(ctx context.Context) ([]Message, error) {
 		logger.WithError(err).Debug("invalid action for current state")
 		return nil, HandshakeFailedError{err}
 	}
+	defer func() {
+		// A failed handshake leaves us unconnected so that it may be
+		// retried per the server's advice
+		if err != nil {
+			_ = b.stateMachine.ProcessEvent(timeout)
+		}
+	}()
 	builder := NewHandshakeRequestBuilder()
+	builder.AddIDGenerator(b.ids)
 	if err := builder.AddVersion("1.0"); err != nil {
 		return nil, HandshakeFailedError{err}
 	}
-	if err := builder.AddSupportedConnectionType("long-polling"); err != nil {
-		return nil, HandshakeFailedError{err}
+	for _, t := range b.transports {
+		if err := builder.AddSupportedConnectionType(t.ConnectionType()); err != nil {
+			return nil, HandshakeFailedError{err}
+		}
 	}
 	ms, err := builder.Build()
 	if err != nil {
 		return nil, HandshakeFailedError{err}
 	}
-	resp, err := b.request(ctx, ms)
+	// The handshake is always carried over the default transport since we
+	// do not yet know which connection types the server supports
+	response, err = b.exchange(ctx, b.defaultTransport, ms)
 	if err != nil {
 		logger.WithError(err).Debug("error during request")
-		return nil, HandshakeFailedError{err}
-	}
-
-	response, err := b.parseResponse(resp)
-	if err != nil {
-		logger.WithError(err).Debug("error parsing response")
 		return response, HandshakeFailedError{err}
 	}
 	if len(response) > 1 {
@@ -108,9 +120,15 @@ func (b *BayeuxClient) Handshake(ctx context.Context) ([]Message, error) {
 		return response, HandshakeFailedError{ErrBadChannel}
 	}
 	if !message.Successful {
-		return response, newHandshakeError(message.Error)
+		return response, newHandshakeError(message)
 	}
 	b.state.SetClientID(message.ClientID)
+	b.state.ClearConnectIDs()
+	if message.Advice == nil {
+		// Any advice to handshake has now been followed
+		b.state.SetAdvice(b.state.GetAdvice().withReconnect(ReconnectRetry))
+	}
+	b.negotiateTransport(message.SupportedConnectionTypes)
 	_ = b.stateMachine.ProcessEvent(successfullyConnected)
 	logger.WithField("duration", time.Since(start)).Debug("finishing")
 	return response, nil
@@ -128,28 +146,40 @@ func (b *BayeuxClient) Connect(ctx context.Context) ([]Message, error) {
 		return nil, ErrClientNotConnected
 	}
 	builder := NewConnectRequestBuilder()
+	builder.AddIDGenerator(b.ids)
 	builder.AddClientID(clientID)
-	_ = builder.AddConnectionType(ConnectionTypeLongPolling)
+	_ = builder.AddConnectionType(b.state.GetTransport().ConnectionType())
 	ms, err := builder.Build()
 	if err != nil {
 		return nil, ConnectionFailedError{err}
 	}
 
-	resp, err := b.request(ctx, ms)
-	if err != nil {
-		logger.WithError(err).Debug("error during request")
-		return nil, ConnectionFailedError{err}
+	// The server may hold the request for as long as the advised timeout
+	// so give it that long plus an allowance for the network
+	if longPoll := b.state.GetAdvice().TimeoutAsDuration(); longPoll > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, longPoll+maxNetworkDelay)
+		defer cancel()
 	}
 
-	response, err := b.parseResponse(resp)
+	// A /meta/connect left outstanding by an earlier call, e.g. on a
+	// websocket, may be the one answered
+	b.state.AddConnectID(ms[0].ID)
+	response, err := b.request(ctx, ms)
 	if err != nil {
-		logger.WithError(err).Debug("error parsing response")
+		logger.WithError(err).Debug("error during request")
 		return response, ConnectionFailedError{err}
 	}
 
+	if advice := b.state.GetAdvice(); advice.ShouldHandshake() || advice.MustNotRetryOrHandshake() {
+		// Our session is no longer usable so we are no longer connected
+		logger.WithField("reconnect", advice.Reconnect).Debug("session ended by advice")
+		_ = b.stateMachine.ProcessEvent(timeout)
+	}
+
 	for _, m := range response {
 		if m.Channel == MetaConnect && !m.Successful {
-			return response, ConnectionFailedError{ErrFailedToConnect}
+			return response, ConnectionFailedError{UnsuccessfulReplyError{m, ErrFailedToConnect}}
 		}
 	}
 	logger.WithField("duration", time.Since(start)).Debug("finishing")
@@ -169,6 +199,7 @@ func (b *BayeuxClient) Subscribe(ctx context.Context, subscriptions []Channel) (
 	}
 
 	builder := NewSubscribeRequestBuilder()
+	builder.AddIDGenerator(b.ids)
 	builder.AddClientID(clientID)
 	for _, s := range subscriptions {
 		if err := builder.AddSubscription(s); err != nil {
@@ -181,21 +212,16 @@ func (b *BayeuxClient) Subscribe(ctx context.Context, subscriptions []Channel) (
 		return nil, SubscriptionFailedError{subscriptions, err}
 	}
 
-	resp, err := b.request(ctx, ms)
-	if err != nil {
-		return nil, SubscriptionFailedError{subscriptions, err}
-	}
-
-	response, err := b.parseResponse(resp)
+	response, err := b.request(ctx, ms)
 	if err != nil {
-		return nil, SubscriptionFailedError{subscriptions, err}
+		return response, SubscriptionFailedError{subscriptions, err}
 	}
 
 	for _, m := range response {
 		if m.Channel == MetaSubscribe && !m.Successful {
-			return nil, SubscriptionFailedError{
+			return response, SubscriptionFailedError{
 				Channels: subscriptions,
-				Err:      newSubscribeError(m.Error),
+				Err:      newSubscribeError(m),
 			}
 		}
 	}
@@ -203,6 +229,21 @@ func (b *BayeuxClient) Subscribe(ctx context.Context, subscriptions []Channel) (
 	return response, nil
 }
 
+// RetrySubscription is called by an extension, from its Incoming hook, once
+// it has dealt with the reason the server rejected the subscription to ch,
+// e.g. an invalid replay ID. The Client then subscribes to ch again. Callers
+// of Subscribe on a BayeuxClient of their own must do so themselves.
+func (b *BayeuxClient) RetrySubscription(ch Channel) {
+	b.logger.WithField("at", "retrySubscription").WithField("channel", ch).Debug("extension asked to subscribe again")
+	b.state.AddRetry(ch)
+}
+
+// takeRetries returns the channels among channels whose subscription an
+// extension asked to be sent again
+func (b *BayeuxClient) takeRetries(channels []Channel) []Channel {
+	return b.state.TakeRetries(channels)
+}
+
 // Unsubscribe issues a MetaUnsubscribe request to the server to subscribe to the
 // channels in the subscriptions slice
 func (b *BayeuxClient) Unsubscribe(ctx context.Context, subscriptions []Channel) ([]Message, error) {
@@ -212,6 +253,7 @@ func (b *BayeuxClient) Unsubscribe(ctx context.Context, subscriptions []Channel)
 	}
 
 	builder := NewUnsubscribeRequestBuilder()
+	builder.AddIDGenerator(b.ids)
 	builder.AddClientID(clientID)
 	for _, s := range subscriptions {
 		if err := builder.AddSubscription(s); err != nil {
@@ -224,12 +266,7 @@ func (b *BayeuxClient) Unsubscribe(ctx context.Context, subscriptions []Channel)
 		return nil, UnsubscribeFailedError{subscriptions, err}
 	}
 
-	resp, err := b.request(ctx, ms)
-	if err != nil {
-		return nil, UnsubscribeFailedError{subscriptions, err}
-	}
-
-	response, err := b.parseResponse(resp)
+	response, err := b.request(ctx, ms)
 	if err != nil {
 		return response, UnsubscribeFailedError{subscriptions, err}
 	}
@@ -238,10 +275,62 @@ func (b *BayeuxClient) Unsubscribe(ctx context.Context, subscriptions []Channel)
 		if m.Channel == MetaUnsubscribe && !m.Successful {
 			return response, UnsubscribeFailedError{
 				Channels: subscriptions,
-				Err:      newUnsubscribeError(m.Error),
+				Err:      newUnsubscribeError(m),
+			}
+		}
+	}
+	return response, nil
+}
+
+// Publish sends the provided messages to the Bayeux server and checks the
+// server's response to each one. Per the specification, publish requests
+// may be sent on a connection separate from the one used for /meta/connect.
+//
+// See also: https://docs.cometd.org/current/reference/#_two_connection_operation
+func (b *BayeuxClient) Publish(ctx context.Context, messages []Message) ([]Message, error) {
+	logger := b.logger.WithField("at", "publish")
+	start := time.Now()
+	logger.Debug("starting")
+	channels := make([]Channel, 0, len(messages))
+	for _, m := range messages {
+		channels = append(channels, m.Channel)
+	}
+
+	clientID := b.state.GetClientID()
+	if !b.stateMachine.IsConnected() || clientID == "" {
+		logger.Debug("cannot publish because client is not connected")
+		return nil, PublishFailedError{channels, ErrClientNotConnected}
+	}
+
+	builder := NewPublishRequestBuilder()
+	builder.AddIDGenerator(b.ids)
+	builder.AddClientID(clientID)
+	for _, m := range messages {
+		if err := builder.AddMessage(m); err != nil {
+			return nil, PublishFailedError{channels, err}
+		}
+	}
+
+	ms, err := builder.Build()
+	if err != nil {
+		return nil, PublishFailedError{channels, err}
+	}
+
+	response, err := b.request(ctx, ms)
+	if err != nil {
+		logger.WithError(err).Debug("error during request")
+		return response, PublishFailedError{channels, err}
+	}
+
+	for _, m := range response {
+		if isPublishReply(m, ms) && !m.Successful {
+			return response, PublishFailedError{
+				Channels: []Channel{m.Channel},
+				Err:      newPublishError(m),
 			}
 		}
 	}
+	logger.WithField("duration", time.Since(start)).Debug("finishing")
 	return response, nil
 }
 
@@ -254,18 +343,17 @@ func (b *BayeuxClient) Disconnect(ctx context.Context) ([]Message, error) {
 	}
 
 	builder := NewDisconnectRequestBuilder()
+	builder.AddIDGenerator(b.ids)
 	builder.AddClientID(clientID)
 	ms, err := builder.Build()
 	if err != nil {
 		return nil, DisconnectFailedError{err}
 	}
 
-	resp, err := b.request(ctx, ms)
-	if err != nil {
-		return nil, DisconnectFailedError{err}
+	response, err := b.request(ctx, ms)
+	if closeErr := b.state.GetTransport().Close(); closeErr != nil {
+		b.logger.WithError(closeErr).Debug("error closing transport")
 	}
-
-	response, err := b.parseResponse(resp)
 	if err != nil {
 		return response, DisconnectFailedError{err}
 	}
@@ -278,68 +366,267 @@ func (b *BayeuxClient) Disconnect(ctx context.Context) ([]Message, error) {
 	return response, nil
 }
 
+// Advice returns the most recent advice received from the server in reply to
+// any request. Until the server provides advice, the client retries.
+//
+// See also: https://docs.cometd.org/current/reference/#_bayeux_advice
+func (b *BayeuxClient) Advice() Advice {
+	return b.state.GetAdvice()
+}
+
+// ServerAddress returns the address of the server requests are currently
+// sent to
+func (b *BayeuxClient) ServerAddress() string {
+	return b.state.GetServerAddress().String()
+}
+
+// AddServerAddress adds an alternate server to fail over to. Servers are
+// tried in the order they were added, after the one given to
+// NewBayeuxClient. Only transports implementing RelocatableTransport are
+// pointed at the new server when failing over.
+func (b *BayeuxClient) AddServerAddress(serverAddress string) error {
+	parsedAddress, err := url.Parse(serverAddress)
+	if err != nil {
+		return err
+	}
+	b.state.AddServerAddress(parsedAddress)
+	return nil
+}
+
 // UseExtension adds the provided MessageExtender to the list of known
-// extensions
+// extensions and then calls its Registered method. It is adapted with
+// AdaptMessageExtender so it never drops or fails a message.
 func (b *BayeuxClient) UseExtension(ext MessageExtender) error {
-	if slices.Contains(b.exts, ext) {
-		return AlreadyRegisteredError{ext}
+	return b.addExtension(ext, AdaptMessageExtender(ext))
+}
+
+// AddExtension adds the provided Extension to the list of known extensions
+// and then calls its Registered method. Extensions see outgoing messages in
+// the order they were registered and incoming messages in the reverse order.
+func (b *BayeuxClient) AddExtension(ext Extension) error {
+	return b.addExtension(ext, ext)
+}
+
+func (b *BayeuxClient) addExtension(ext ExtensionLifecycle, adapted Extension) error {
+	name, err := b.exts.Add(ext, adapted)
+	if err != nil {
+		return err
 	}
-	b.exts = append(b.exts, ext)
+	ext.Registered(name, b)
 	return nil
 }
 
-func (b *BayeuxClient) request(ctx context.Context, ms []Message) (*http.Response, error) {
-	for _, ext := range b.exts {
-		for _, m := range ms {
-			ext.Outgoing(&m)
+// RemoveExtension removes the provided MessageExtender or Extension from the
+// list of known extensions and then calls its Unregistered method
+func (b *BayeuxClient) RemoveExtension(ext ExtensionLifecycle) error {
+	if err := b.exts.Remove(ext); err != nil {
+		return err
+	}
+	ext.Unregistered()
+	return nil
+}
+
+// UseTransport registers an additional Transport. Transports are offered to
+// the server during the handshake in the order they were registered, ahead of
+// the default long-polling transport, and the first one the server supports
+// is used for every request after the handshake. Registering a Transport with
+// the same connection type as one already registered replaces it, which
+// allows replacing the default long-polling transport. The handshake itself
+// is always sent with the long-polling transport, or its replacement.
+//
+// Any valid connection type may be registered, including ones of your own
+// such as an in-process transport. Since the server decides which connection
+// types it supports, a Transport it does not list in its reply to the
+// handshake is never used.
+//
+// Transports must be registered before calling Handshake.
+func (b *BayeuxClient) UseTransport(t Transport) error {
+	if err := validateConnectionType(t.ConnectionType()); err != nil {
+		return err
+	}
+
+	for i, existing := range b.transports {
+		if existing.ConnectionType() == t.ConnectionType() {
+			b.transports[i] = t
+			if existing == b.defaultTransport {
+				b.defaultTransport = t
+			}
+			return nil
 		}
 	}
 
-	var buf bytes.Buffer
-	if err := json.NewEncoder(&buf).Encode(ms); err != nil {
+	// Keep the default transport last so it is only used when nothing
+	// else is supported by the server
+	b.transports = slices.Insert(b.transports, len(b.transports)-1, t)
+	return nil
+}
+
+// UseIDGenerator replaces the generator of the id given to every message
+// the BayeuxClient sends. The default is a CounterIDGenerator.
+//
+// The generator must be set before calling Handshake.
+func (b *BayeuxClient) UseIDGenerator(ids IDGenerator) {
+	b.ids = ids
+}
+
+// request sends ms over the transport negotiated during the handshake
+func (b *BayeuxClient) request(ctx context.Context, ms []Message) ([]Message, error) {
+	return b.exchange(ctx, b.state.GetTransport(), ms)
+}
+
+// exchange sends ms over t and checks that every reply to them carries the
+// id of one of the requests. Replies without an id are accepted since the
+// specification does not require servers to send one. Replies to published
+// messages are only recognised by their id so they need no checking.
+func (b *BayeuxClient) exchange(ctx context.Context, t Transport, ms []Message) ([]Message, error) {
+	messages, err := b.send(ctx, t, ms)
+	if err != nil {
+		return messages, err
+	}
+
+	ids := make(map[string]bool, len(ms))
+	channels := make([]Channel, 0, len(ms))
+	for _, m := range ms {
+		ids[m.ID] = true
+		channels = append(channels, m.Channel)
+	}
+	for _, m := range messages {
+		if m.ID == "" || m.Channel.Type() != MetaChannel || !slices.Contains(channels, m.Channel) {
+			continue
+		}
+		if m.Channel == MetaConnect && b.state.AnswerConnectID(m.ID) {
+			continue
+		}
+		if !ids[m.ID] {
+			b.logger.WithField("channel", m.Channel).WithField("id", m.ID).Debug("unexpected reply")
+			return messages, UnexpectedReplyError{m.Channel, m.ID, m}
+		}
+	}
+	return messages, nil
+}
+
+func (b *BayeuxClient) send(ctx context.Context, t Transport, ms []Message) ([]Message, error) {
+	ms, err := b.exts.Outgoing(ms)
+	if err != nil {
+		return nil, err
+	}
+	if len(ms) == 0 {
+		b.logger.Debug("extensions dropped every message")
+		return []Message{}, nil
+	}
+
+	messages, err := t.Send(ctx, ms)
+	if err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", b.serverAddress.String(), &buf)
+	messages, err = b.exts.Incoming(messages)
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	return b.client.Do(req)
-}
 
-func (b *BayeuxClient) parseResponse(resp *http.Response) ([]Message, error) {
-	messages := make([]Message, 0)
-	defer func() {
-		if err := resp.Body.Close(); err != nil {
-			b.logger.WithError(err).Warn("could not close response body")
+	for _, m := range messages {
+		if m.Advice != nil {
+			b.state.SetAdvice(*m.Advice)
+			b.followHosts(*m.Advice)
 		}
-	}()
+	}
+	return messages, nil
+}
 
-	if resp.StatusCode != 200 {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			b.logger.WithError(err).Debug("error reading body")
-		}
+// followHosts moves to the first of the advised hosts when we are advised to
+// handshake again and the current server is not among them, so that the next
+// handshake is sent there. The remaining advised hosts are tried in order if
+// it fails.
+//
+// See also: https://docs.cometd.org/current/reference/#_hosts_advice_field
+func (b *BayeuxClient) followHosts(advice Advice) {
+	if advice.Reconnect != ReconnectHandshake || len(advice.Hosts) == 0 {
+		return
+	}
+	if serverAddress, ok := b.state.AdviseHosts(advice.Hosts); ok {
+		b.logger.WithField("address", serverAddress.String()).Debug("following hosts advice")
+		b.relocate(serverAddress)
+	}
+}
+
+// serverCount returns the number of servers we know of
+func (b *BayeuxClient) serverCount() int {
+	return b.state.ServerCount()
+}
 
-		return nil, BadResponseError{resp.StatusCode, resp.Status, body}
+// failover moves to the next known server, wrapping around to the first.
+// Since our session belongs to the previous server, a new handshake is
+// required before anything else.
+func (b *BayeuxClient) failover() {
+	serverAddress := b.state.NextServerAddress()
+	b.logger.WithField("address", serverAddress.String()).Debug("failing over")
+	b.relocate(serverAddress)
+	b.endSession()
+}
+
+// endSession leaves us unconnected so that a new handshake can be made
+func (b *BayeuxClient) endSession() {
+	_ = b.stateMachine.ProcessEvent(timeout)
+}
+
+// relocate points every transport that supports it at serverAddress
+func (b *BayeuxClient) relocate(serverAddress *url.URL) {
+	for _, t := range b.transports {
+		if rt, ok := t.(RelocatableTransport); ok {
+			rt.SetServerAddress(serverAddress)
+		}
 	}
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
-		return nil, err
+// negotiateTransport picks the first of our transports whose connection type
+// the server supports, falling back to the default transport otherwise
+func (b *BayeuxClient) negotiateTransport(supported []string) {
+	selected := b.defaultTransport
+	for _, t := range b.transports {
+		if slices.Contains(supported, t.ConnectionType()) {
+			selected = t
+			break
+		}
 	}
-	for _, ext := range b.exts {
-		for _, m := range messages {
-			ext.Incoming(&m)
+
+	if previous := b.state.SetTransport(selected); previous != nil {
+		if err := previous.Close(); err != nil {
+			b.logger.WithError(err).Debug("error closing previous transport")
 		}
 	}
-	return messages, nil
+	b.logger.WithField("connectionType", selected.ConnectionType()).Debug("negotiated transport")
+}
+
+// useWebSocket adds the websocket transport as the preferred connection type
+func (b *BayeuxClient) useWebSocket() {
+	_ = b.UseTransport(newWebSocketTransport(b.client, b.state.GetServerAddress(), b.logger))
+}
+
+// isPublishReply reports whether m is the server's reply to one of the
+// published messages rather than a message being delivered on the same
+// channel. Replies carry the id of the message they answer but no data.
+func isPublishReply(m Message, published []Message) bool {
+	if m.ID == "" || len(m.Data) > 0 {
+		return false
+	}
+	return slices.ContainsFunc(published, func(p Message) bool {
+		return p.ID == m.ID && p.Channel == m.Channel
+	})
 }
 
 type clientState struct {
-	clientID string
-	lock     sync.RWMutex
+	clientID  string
+	transport Transport
+	advice    *Advice
+	servers   []*url.URL
+	server    int
+	// connects holds the ids of /meta/connect requests, oldest first,
+	// which may still be answered
+	connects []string
+	// retries holds the channels extensions asked to subscribe to again
+	retries []Channel
+	lock    sync.RWMutex
 }
 
 func (cs *clientState) GetClientID() string {
@@ -353,3 +640,145 @@ func (cs *clientState) SetClientID(clientID string) {
 	defer cs.lock.Unlock()
 	cs.clientID = clientID
 }
+
+// AddConnectID records the id of a /meta/connect request awaiting its reply
+func (cs *clientState) AddConnectID(id string) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.connects = append(cs.connects, id)
+}
+
+// AnswerConnectID reports whether id belongs to a /meta/connect request
+// awaiting its reply. It and every earlier request are no longer awaited
+// since only one may be outstanding at a time.
+func (cs *clientState) AnswerConnectID(id string) bool {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	i := slices.Index(cs.connects, id)
+	if i < 0 {
+		return false
+	}
+	cs.connects = cs.connects[i+1:]
+	return true
+}
+
+// ClearConnectIDs forgets the /meta/connect requests of a previous session
+func (cs *clientState) ClearConnectIDs() {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.connects = nil
+}
+
+// AddRetry records that the subscription to ch should be sent again
+func (cs *clientState) AddRetry(ch Channel) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if !slices.Contains(cs.retries, ch) {
+		cs.retries = append(cs.retries, ch)
+	}
+}
+
+// TakeRetries returns the channels among channels whose subscription should
+// be sent again and forgets them
+func (cs *clientState) TakeRetries(channels []Channel) []Channel {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	var retries []Channel
+	cs.retries = slices.DeleteFunc(cs.retries, func(ch Channel) bool {
+		if slices.Contains(channels, ch) {
+			retries = append(retries, ch)
+			return true
+		}
+		return false
+	})
+	return retries
+}
+
+func (cs *clientState) GetTransport() Transport {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return cs.transport
+}
+
+// SetTransport stores the negotiated transport and returns the one it
+// replaces, if any
+func (cs *clientState) SetTransport(t Transport) Transport {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	previous := cs.transport
+	cs.transport = t
+	return previous
+}
+
+// GetAdvice returns the last advice stored or the default advice if the
+// server has not sent any
+func (cs *clientState) GetAdvice() Advice {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	if cs.advice == nil {
+		return defaultAdvice
+	}
+	return *cs.advice
+}
+
+func (cs *clientState) SetAdvice(advice Advice) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	if advice.Reconnect == "" {
+		advice.Reconnect = defaultAdvice.Reconnect
+	}
+	cs.advice = &advice
+}
+
+func (cs *clientState) GetServerAddress() *url.URL {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return cs.servers[cs.server]
+}
+
+func (cs *clientState) AddServerAddress(serverAddress *url.URL) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.servers = append(cs.servers, serverAddress)
+}
+
+func (cs *clientState) ServerCount() int {
+	cs.lock.RLock()
+	defer cs.lock.RUnlock()
+	return len(cs.servers)
+}
+
+// NextServerAddress moves to the next server and returns its address
+func (cs *clientState) NextServerAddress() *url.URL {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	cs.server = (cs.server + 1) % len(cs.servers)
+	return cs.servers[cs.server]
+}
+
+// AdviseHosts puts the advised hosts ahead of every other server we know of,
+// unless the current server is one of them, and returns the new current
+// server. Advised hosts keep the scheme and path of the current server.
+func (cs *clientState) AdviseHosts(hosts []string) (*url.URL, bool) {
+	cs.lock.Lock()
+	defer cs.lock.Unlock()
+	current := cs.servers[cs.server]
+	if slices.Contains(hosts, current.Host) || slices.Contains(hosts, current.Hostname()) {
+		return nil, false
+	}
+
+	servers := make([]*url.URL, 0, len(hosts)+len(cs.servers))
+	for _, host := range hosts {
+		advised := *current
+		advised.Host = host
+		servers = append(servers, &advised)
+	}
+	for _, server := range cs.servers {
+		if !slices.ContainsFunc(servers, func(u *url.URL) bool { return u.Host == server.Host }) {
+			servers = append(servers, server)
+		}
+	}
+	cs.servers = servers
+	cs.server = 0
+	return cs.servers[0], true
+}
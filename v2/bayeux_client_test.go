@@ -1,6 +1,13 @@
 package gobayeux
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+)
 
 func TestClientState_GetClientID(t *testing.T) {
 	want := "fakeClientID"
@@ -19,3 +26,452 @@ func TestClientState_SetClientID(t *testing.T) {
 		t.Errorf("error retrieving client ID; want %s got %s", want, got)
 	}
 }
+
+func TestClientState_AdviseHosts(t *testing.T) {
+	testCases := []struct {
+		name     string
+		hosts    []string
+		moved    bool
+		expected []string
+	}{
+		{
+			"current server advised",
+			[]string{"b.example.com", "a.example.com"},
+			false,
+			[]string{"https://a.example.com/cometd", "https://c.example.com/cometd"},
+		},
+		{
+			"current server not advised",
+			[]string{"b.example.com:8443", "c.example.com"},
+			true,
+			[]string{"https://b.example.com:8443/cometd", "https://c.example.com/cometd", "https://a.example.com/cometd"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			state := clientState{}
+			for _, address := range []string{"https://a.example.com/cometd", "https://c.example.com/cometd"} {
+				u, err := url.Parse(address)
+				if err != nil {
+					t.Fatalf("failed to parse %s (%v)", address, err)
+				}
+				state.servers = append(state.servers, u)
+			}
+
+			current, moved := state.AdviseHosts(tc.hosts)
+			if moved != tc.moved {
+				t.Fatalf("expected moved to be %t but got %t", tc.moved, moved)
+			}
+			if moved && current.String() != tc.expected[0] {
+				t.Errorf("expected to move to %s but moved to %s", tc.expected[0], current)
+			}
+
+			got := make([]string, 0, len(state.servers))
+			for _, u := range state.servers {
+				got = append(got, u.String())
+			}
+			if !slices.Equal(got, tc.expected) {
+				t.Errorf("expected servers %v but got %v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestBayeuxClient_UseTransport(t *testing.T) {
+	testCases := []struct {
+		name       string
+		transports []Transport
+		want       []string
+		shouldErr  bool
+	}{
+		{
+			"default transport only",
+			nil,
+			[]string{ConnectionTypeLongPolling},
+			false,
+		},
+		{
+			"additional transports are preferred in registration order",
+			[]Transport{
+				&fakeTransport{connectionType: ConnectionTypeWebSocket},
+				&fakeTransport{connectionType: ConnectionTypeCallbackPolling},
+			},
+			[]string{ConnectionTypeWebSocket, ConnectionTypeCallbackPolling, ConnectionTypeLongPolling},
+			false,
+		},
+		{
+			"replacing the default transport",
+			[]Transport{&fakeTransport{connectionType: ConnectionTypeLongPolling}},
+			[]string{ConnectionTypeLongPolling},
+			false,
+		},
+		{
+			"custom connection type",
+			[]Transport{&fakeTransport{connectionType: "in-process"}},
+			[]string{"in-process", ConnectionTypeLongPolling},
+			false,
+		},
+		{
+			"invalid connection type",
+			[]Transport{&fakeTransport{connectionType: "in process"}},
+			[]string{ConnectionTypeLongPolling},
+			true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := NewBayeuxClient(nil, nil, "https://example.com", nil)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+
+			for _, tr := range tc.transports {
+				err = b.UseTransport(tr)
+			}
+			if err != nil && !tc.shouldErr {
+				t.Fatalf("expected UseTransport to succeed but got %q", err)
+			}
+			if err == nil && tc.shouldErr {
+				t.Fatal("expected an error but didn't get one")
+			}
+
+			got := make([]string, 0, len(b.transports))
+			for _, tr := range b.transports {
+				got = append(got, tr.ConnectionType())
+			}
+			if !slices.Equal(tc.want, got) {
+				t.Errorf("expected transports %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestBayeuxClient_NegotiatesTransport(t *testing.T) {
+	testCases := []struct {
+		name      string
+		supported []string
+		want      string
+	}{
+		{"server supports the preferred transport", []string{ConnectionTypeCallbackPolling, ConnectionTypeLongPolling}, ConnectionTypeCallbackPolling},
+		{"server supports only long-polling", []string{ConnectionTypeLongPolling}, ConnectionTypeLongPolling},
+		{"server supports nothing we know", []string{ConnectionTypeIFrame}, ConnectionTypeLongPolling},
+		{"server supports a custom transport", []string{"in-process", ConnectionTypeLongPolling}, "in-process"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			longPolling := &fakeTransport{
+				connectionType: ConnectionTypeLongPolling,
+				reply: func(ms []Message) []Message {
+					return []Message{{
+						Channel:                  MetaHandshake,
+						ClientID:                 "Un1q31d3nt1f13r",
+						SupportedConnectionTypes: tc.supported,
+						Successful:               true,
+					}}
+				},
+			}
+			b, err := NewBayeuxClient(nil, nil, "https://example.com", nil)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			for _, tr := range []Transport{longPolling, &fakeTransport{connectionType: ConnectionTypeCallbackPolling}, &fakeTransport{connectionType: "in-process"}} {
+				if err := b.UseTransport(tr); err != nil {
+					t.Fatalf("failed to register transport (%v)", err)
+				}
+			}
+
+			if _, err := b.Handshake(context.Background()); err != nil {
+				t.Fatalf("expected handshake to succeed but got %q", err)
+			}
+
+			sent := longPolling.sent[0][0].SupportedConnectionTypes
+			if want := []string{ConnectionTypeCallbackPolling, "in-process", ConnectionTypeLongPolling}; !slices.Equal(want, sent) {
+				t.Errorf("expected handshake to advertise %v, got %v", want, sent)
+			}
+
+			if got := b.state.GetTransport().ConnectionType(); got != tc.want {
+				t.Errorf("expected to negotiate %s, got %s", tc.want, got)
+			}
+		})
+	}
+}
+
+type fakeTransport struct {
+	connectionType string
+	reply          func([]Message) []Message
+
+	mu   sync.Mutex
+	sent [][]Message
+}
+
+func (t *fakeTransport) ConnectionType() string {
+	return t.connectionType
+}
+
+func (t *fakeTransport) Send(ctx context.Context, ms []Message) ([]Message, error) {
+	t.mu.Lock()
+	t.sent = append(t.sent, ms)
+	t.mu.Unlock()
+
+	if t.reply == nil {
+		return []Message{}, nil
+	}
+	return t.reply(ms), nil
+}
+
+func (t *fakeTransport) Close() error {
+	return nil
+}
+
+func TestBayeuxClient_RemembersAdvice(t *testing.T) {
+	replies := [][]Message{
+		{{
+			Channel:                  MetaHandshake,
+			ClientID:                 "Un1q31d3nt1f13r",
+			SupportedConnectionTypes: []string{ConnectionTypeLongPolling},
+			Successful:               true,
+			Advice:                   &Advice{Reconnect: ReconnectRetry, Interval: 10, Timeout: 1000},
+		}},
+		{{Channel: MetaConnect, Successful: true}},
+		{{Channel: MetaConnect, Successful: false, Advice: &Advice{Reconnect: ReconnectHandshake}}},
+		{{Channel: MetaHandshake, ClientID: "N3wCl13nt1d", Successful: true}},
+	}
+	transport := &fakeTransport{
+		connectionType: ConnectionTypeLongPolling,
+		reply: func([]Message) []Message {
+			reply := replies[0]
+			replies = replies[1:]
+			return reply
+		},
+	}
+
+	b, err := NewBayeuxClient(nil, nil, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if err := b.UseTransport(transport); err != nil {
+		t.Fatalf("failed to register transport (%v)", err)
+	}
+
+	if got := b.Advice(); !got.ShouldRetry() {
+		t.Errorf("expected default advice to retry, got %q", got.Reconnect)
+	}
+
+	if _, err := b.Handshake(context.Background()); err != nil {
+		t.Fatalf("expected handshake to succeed but got %q", err)
+	}
+
+	if _, err := b.Connect(context.Background()); err != nil {
+		t.Fatalf("expected connect to succeed but got %q", err)
+	}
+	if got := b.Advice(); got.Interval != 10 || got.Timeout != 1000 {
+		t.Errorf("expected advice from the handshake to be remembered, got %+v", got)
+	}
+
+	if _, err := b.Connect(context.Background()); err == nil {
+		t.Fatal("expected connect to fail but it didn't")
+	}
+	if got := b.Advice(); !got.ShouldHandshake() {
+		t.Errorf("expected advice to handshake, got %q", got.Reconnect)
+	}
+	if b.stateMachine.IsConnected() {
+		t.Error("expected advice to handshake to leave the client unconnected")
+	}
+
+	if _, err := b.Handshake(context.Background()); err != nil {
+		t.Fatalf("expected to be able to handshake again but got %q", err)
+	}
+	if got := b.Advice(); !got.ShouldRetry() {
+		t.Errorf("expected advice to retry after handshaking again, got %q", got.Reconnect)
+	}
+}
+
+func TestBayeuxClient_ChecksReplyIDs(t *testing.T) {
+	// The first /meta/connect only returns a delivery so its reply may
+	// come with the second
+	delivery := Message{Channel: "/foo/bar", Data: []byte(`{}`), ID: "d3l1v3ry"}
+	testCases := []struct {
+		name  string
+		reply func(first, second Message) Message
+		err   bool
+	}{
+		{"matching id", func(_, second Message) Message { return Message{Channel: MetaConnect, ID: second.ID, Successful: true} }, false},
+		{"no id", func(_, _ Message) Message { return Message{Channel: MetaConnect, Successful: true} }, false},
+		{"outstanding id", func(first, _ Message) Message { return Message{Channel: MetaConnect, ID: first.ID, Successful: true} }, false},
+		{"unknown id", func(_, _ Message) Message { return Message{Channel: MetaConnect, ID: "unkn0wn", Successful: true} }, true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			var sent []Message
+			transport := &fakeTransport{
+				connectionType: ConnectionTypeLongPolling,
+				reply: func(ms []Message) []Message {
+					sent = append(sent, ms[0])
+					switch len(sent) {
+					case 1:
+						return []Message{{Channel: MetaHandshake, ID: ms[0].ID, ClientID: "Un1q31d3nt1f13r", Successful: true}}
+					case 2:
+						return []Message{delivery}
+					}
+					return []Message{tc.reply(sent[1], sent[2])}
+				},
+			}
+			b, err := NewBayeuxClient(nil, nil, "https://example.com", nil)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			b.UseIDGenerator(RandomIDGenerator{})
+			if err := b.UseTransport(transport); err != nil {
+				t.Fatalf("failed to register transport (%v)", err)
+			}
+
+			if _, err := b.Handshake(context.Background()); err != nil {
+				t.Fatalf("expected handshake to succeed but got %q", err)
+			}
+			if _, err := b.Connect(context.Background()); err != nil {
+				t.Fatalf("expected a delivery to be accepted but got %q", err)
+			}
+			_, err = b.Connect(context.Background())
+			var unexpected UnexpectedReplyError
+			if tc.err != errors.As(err, &unexpected) {
+				t.Errorf("expected an UnexpectedReplyError to be %t but got %v", tc.err, err)
+			}
+			if tc.err && unexpected.Reply.ID != unexpected.ID {
+				t.Errorf("expected the unexpected reply but got %+v", unexpected.Reply)
+			}
+			if sent[1].ID == sent[2].ID {
+				t.Errorf("expected every request to have its own id but got %q twice", sent[1].ID)
+			}
+		})
+	}
+}
+
+func TestBayeuxClient_PublishMatchesRepliesByID(t *testing.T) {
+	testCases := []struct {
+		name      string
+		reply     func(published Message) []Message
+		shouldErr bool
+	}{
+		{
+			"data-less broadcast on the same channel",
+			func(published Message) []Message {
+				return []Message{
+					{Channel: published.Channel, ID: "br0adc4st"},
+					{Channel: published.Channel, ID: published.ID, Successful: true},
+				}
+			},
+			false,
+		},
+		{
+			"unsuccessful reply",
+			func(published Message) []Message {
+				return []Message{{Channel: published.Channel, ID: published.ID, Error: "403::Publish denied"}}
+			},
+			true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &fakeTransport{
+				connectionType: ConnectionTypeLongPolling,
+				reply: func(ms []Message) []Message {
+					if ms[0].Channel == MetaHandshake {
+						return []Message{{Channel: MetaHandshake, ClientID: "Un1q31d3nt1f13r", Successful: true}}
+					}
+					return tc.reply(ms[0])
+				},
+			}
+			b, err := NewBayeuxClient(nil, nil, "https://example.com", nil)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			if err := b.UseTransport(transport); err != nil {
+				t.Fatalf("failed to register transport (%v)", err)
+			}
+			if _, err := b.Handshake(context.Background()); err != nil {
+				t.Fatalf("expected handshake to succeed but got %q", err)
+			}
+
+			_, err = b.Publish(context.Background(), []Message{{Channel: "/foo/bar", Data: []byte(`{}`)}})
+			if tc.shouldErr != (err != nil) {
+				t.Errorf("expected an error to be %t but got %v", tc.shouldErr, err)
+			}
+		})
+	}
+}
+
+func TestBayeuxClient_WrapsUnsuccessfulReplies(t *testing.T) {
+	testCases := []struct {
+		name    string
+		channel Channel
+		request func(b *BayeuxClient) error
+	}{
+		{"handshake", MetaHandshake, func(b *BayeuxClient) error {
+			_, err := b.Handshake(context.Background())
+			return err
+		}},
+		{"connect", MetaConnect, func(b *BayeuxClient) error {
+			_, err := b.Connect(context.Background())
+			return err
+		}},
+		{"subscribe", MetaSubscribe, func(b *BayeuxClient) error {
+			_, err := b.Subscribe(context.Background(), []Channel{"/foo/bar"})
+			return err
+		}},
+		{"unsubscribe", MetaUnsubscribe, func(b *BayeuxClient) error {
+			_, err := b.Unsubscribe(context.Background(), []Channel{"/foo/bar"})
+			return err
+		}},
+		{"publish", "/foo/bar", func(b *BayeuxClient) error {
+			_, err := b.Publish(context.Background(), []Message{{Channel: "/foo/bar", Data: []byte(`{}`)}})
+			return err
+		}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			transport := &fakeTransport{
+				connectionType: ConnectionTypeLongPolling,
+				reply: func(ms []Message) []Message {
+					m := ms[0]
+					if m.Channel == tc.channel {
+						return []Message{{Channel: m.Channel, ID: m.ID, Subscription: m.Subscription, Error: "403::Denied"}}
+					}
+					return []Message{{Channel: m.Channel, ID: m.ID, ClientID: "Un1q31d3nt1f13r", Successful: true}}
+				},
+			}
+			b, err := NewBayeuxClient(nil, nil, "https://example.com", nil)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			if err := b.UseTransport(transport); err != nil {
+				t.Fatalf("failed to register transport (%v)", err)
+			}
+			if tc.channel != MetaHandshake {
+				if _, err := b.Handshake(context.Background()); err != nil {
+					t.Fatalf("expected handshake to succeed but got %q", err)
+				}
+			}
+
+			err = tc.request(b)
+			var replyErr UnsuccessfulReplyError
+			if !errors.As(err, &replyErr) {
+				t.Fatalf("expected an UnsuccessfulReplyError but got %v", err)
+			}
+			if replyErr.Reply.Channel != tc.channel || replyErr.Reply.Error != "403::Denied" {
+				t.Errorf("expected the unsuccessful reply but got %+v", replyErr.Reply)
+			}
+		})
+	}
+}
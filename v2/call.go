@@ -0,0 +1,123 @@
+package gobayeux
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// Call publishes payload, encoded as JSON, to the service channel ch and
+// waits for the server's reply, which is matched to the request by its ID.
+// The reply may arrive in the response to the publish or with a later
+// /meta/connect response.
+//
+// If ctx expires first, its error is returned. If the session ends first,
+// e.g. because the server advised the client to handshake again,
+// ErrCallAbandoned is returned as the server forgets the request along with
+// the session. An unsuccessful reply is returned along with a
+// CallFailedError.
+//
+// See also: https://docs.cometd.org/current/reference/#_concepts_application_remote_call
+func (c *Client) Call(ctx context.Context, ch Channel, payload interface{}) (Message, error) {
+	if !ch.IsValid() || ch.HasWildcard() || ch.Type() != ServiceChannel {
+		return Message{}, InvalidChannelError{ch}
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return Message{}, err
+	}
+
+	id := nextID(c.client.ids)
+	reply := c.calls.Add(id, ch)
+	defer c.calls.Remove(id)
+
+	c.publishLock.Lock()
+	ms, err := c.client.Publish(ctx, []Message{{Channel: ch, Data: data, ID: id}})
+	c.publishLock.Unlock()
+	if err != nil {
+		return Message{}, err
+	}
+	for _, m := range ms {
+		// The response to the publish holds its acknowledgement, which
+		// carries no data, and possibly the reply itself
+		if len(m.Data) > 0 || !m.Successful {
+			c.calls.Resolve(m)
+		}
+	}
+
+	select {
+	case m, ok := <-reply:
+		if !ok {
+			return Message{}, ErrCallAbandoned
+		}
+		if !m.Successful && m.Error != "" {
+			return m, CallFailedError{ch, newCallError(m)}
+		}
+		return m, nil
+	case <-ctx.Done():
+		return Message{}, ctx.Err()
+	case <-c.shutdown:
+		return Message{}, ErrClientNotConnected
+	}
+}
+
+// pendingCalls tracks the calls waiting for a reply by the ID of their
+// request
+type pendingCalls struct {
+	mu    sync.Mutex
+	calls map[string]pendingCall
+}
+
+// pendingCall is a call waiting for a reply on channel
+type pendingCall struct {
+	channel Channel
+	reply   chan Message
+}
+
+func newPendingCalls() *pendingCalls {
+	return &pendingCalls{calls: make(map[string]pendingCall)}
+}
+
+// Add registers a new call whose request on ch has id and returns the
+// channel its reply is sent on
+func (p *pendingCalls) Add(id string, ch Channel) <-chan Message {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	reply := make(chan Message, 1)
+	p.calls[id] = pendingCall{ch, reply}
+	return reply
+}
+
+// Remove forgets the call with id
+func (p *pendingCalls) Remove(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.calls, id)
+}
+
+// Resolve sends m to the call it replies to, the one with the same ID on the
+// same channel, and reports whether there was one
+func (p *pendingCalls) Resolve(m Message) bool {
+	if m.ID == "" {
+		return false
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	call, ok := p.calls[m.ID]
+	if !ok || call.channel != m.Channel {
+		return false
+	}
+	delete(p.calls, m.ID)
+	call.reply <- m
+	return true
+}
+
+// Abandon ends every pending call, whose replies will never arrive
+func (p *pendingCalls) Abandon() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, call := range p.calls {
+		close(call.reply)
+		delete(p.calls, id)
+	}
+}
@@ -0,0 +1,136 @@
+package gobayeux_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/internal/gobayeuxtest"
+)
+
+// echo replies to calls on /service/echo with their data, to those on
+// /service/ack without any and rejects those on /service/reject. Calls on
+// /service/elsewhere are answered on another channel, which is not a reply.
+func echo(m gobayeux.Message) *gobayeux.Message {
+	switch m.Channel {
+	case "/service/echo":
+		return &gobayeux.Message{Channel: m.Channel, ID: m.ID, Data: m.Data, Successful: true}
+	case "/service/ack":
+		return &gobayeux.Message{Channel: m.Channel, ID: m.ID, Successful: true}
+	case "/service/elsewhere":
+		return &gobayeux.Message{Channel: "/foo/bar", ID: m.ID, Data: m.Data, Successful: true}
+	case "/service/reject":
+		return &gobayeux.Message{Channel: m.Channel, ID: m.ID, Data: json.RawMessage(`{}`), Error: "400::Bad request"}
+	}
+	return nil
+}
+
+func TestCall(t *testing.T) {
+	testCases := []struct {
+		name     string
+		channel  gobayeux.Channel
+		timeout  time.Duration
+		expected string
+		err      error
+	}{
+		{"reply", "/service/echo", 5 * time.Second, `{"name":"foo"}`, nil},
+		{"reply without data", "/service/ack", 5 * time.Second, "", nil},
+		{"same ID on another channel", "/service/elsewhere", 100 * time.Millisecond, "", context.DeadlineExceeded},
+		{"unsuccessful reply", "/service/reject", 5 * time.Second, `{}`, gobayeux.CallFailedError{}},
+		{"no reply", "/service/ignored", 100 * time.Millisecond, "", context.DeadlineExceeded},
+		{"not a service channel", "/foo/bar", 5 * time.Second, "", gobayeux.InvalidChannelError{}},
+	}
+
+	server := gobayeuxtest.NewServer(t, gobayeuxtest.WithServiceReplies(echo))
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	errs := client.Start(context.Background())
+	go func() {
+		for err := range errs {
+			t.Errorf("unexpected error from client (%v)", err)
+		}
+	}()
+	// Wait for the client to connect
+	for server.Connects() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), tc.timeout)
+			defer cancel()
+			reply, err := client.Call(ctx, tc.channel, map[string]string{"name": "foo"})
+			switch expected := tc.err.(type) {
+			case nil:
+				if err != nil {
+					t.Fatalf("unexpected error (%v)", err)
+				}
+			case gobayeux.CallFailedError:
+				if !errors.As(err, &expected) {
+					t.Fatalf("expected a CallFailedError but got %v", err)
+				}
+				var replyErr gobayeux.UnsuccessfulReplyError
+				if !errors.As(err, &replyErr) || replyErr.Reply.Channel != tc.channel {
+					t.Errorf("expected the unsuccessful reply but got %v", err)
+				}
+			case gobayeux.InvalidChannelError:
+				if !errors.As(err, &expected) {
+					t.Fatalf("expected an InvalidChannelError but got %v", err)
+				}
+			default:
+				if !errors.Is(err, tc.err) {
+					t.Fatalf("expected %v but got %v", tc.err, err)
+				}
+			}
+			if string(reply.Data) != tc.expected {
+				t.Errorf("expected reply data %s but got %s", tc.expected, reply.Data)
+			}
+		})
+	}
+
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+}
+
+func TestCallAbandonedOnRehandshake(t *testing.T) {
+	server := gobayeuxtest.NewServer(t, gobayeuxtest.WithUnknownClientEvery(5), gobayeuxtest.WithServiceReplies(echo))
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = client.Start(ctx)
+	for server.Connects() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Nothing replies on /service/ignored so the call is waiting when the
+	// client is next advised to handshake. Calls made while the client is
+	// between sessions are not sent at all.
+	_, err = client.Call(ctx, "/service/ignored", "ping")
+	for errors.Is(err, gobayeux.ErrClientNotConnected) {
+		_, err = client.Call(ctx, "/service/ignored", "ping")
+	}
+	if !errors.Is(err, gobayeux.ErrCallAbandoned) {
+		t.Errorf("expected ErrCallAbandoned but got %v", err)
+	}
+
+	if err := client.Disconnect(context.Background()); err != nil && !errors.Is(err, gobayeux.ErrClientNotConnected) {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+}
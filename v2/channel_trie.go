@@ -0,0 +1,83 @@
+package gobayeux
+
+import "strings"
+
+// channelTrie indexes subscriptions by the segments of their channel so that
+// every subscription matching a channel, including wildcard subscriptions,
+// can be found without comparing against each one in turn
+//
+// See also: https://docs.cometd.org/current/reference/#_concepts_channels_wild
+type channelTrie struct {
+	children map[string]*channelTrie
+	subs     []*subscriber
+}
+
+func newChannelTrie() *channelTrie {
+	return &channelTrie{children: make(map[string]*channelTrie)}
+}
+
+// Insert stores subs for channel, replacing anything stored there before
+func (t *channelTrie) Insert(channel Channel, subs []*subscriber) {
+	node := t
+	for _, segment := range channelSegments(channel) {
+		child, ok := node.children[segment]
+		if !ok {
+			child = newChannelTrie()
+			node.children[segment] = child
+		}
+		node = child
+	}
+	node.subs = subs
+}
+
+// Delete removes whatever is stored for channel, pruning nodes that no longer
+// lead to a subscription
+func (t *channelTrie) Delete(channel Channel) {
+	t.delete(channelSegments(channel))
+}
+
+func (t *channelTrie) delete(segments []string) {
+	if len(segments) == 0 {
+		t.subs = nil
+		return
+	}
+
+	child, ok := t.children[segments[0]]
+	if !ok {
+		return
+	}
+	child.delete(segments[1:])
+	if len(child.subs) == 0 && len(child.children) == 0 {
+		delete(t.children, segments[0])
+	}
+}
+
+// Match returns what is stored for every subscription matching channel. A
+// channel may match an exact subscription as well as any number of wildcard
+// subscriptions.
+func (t *channelTrie) Match(channel Channel) []*subscriber {
+	return t.match(channelSegments(channel), nil)
+}
+
+func (t *channelTrie) match(segments []string, matches []*subscriber) []*subscriber {
+	if len(segments) == 0 {
+		return append(matches, t.subs...)
+	}
+
+	// /foo/** matches any number of segments after /foo while /foo/*
+	// matches exactly one
+	if child, ok := t.children["**"]; ok {
+		matches = append(matches, child.subs...)
+	}
+	if child, ok := t.children["*"]; ok && len(segments) == 1 {
+		matches = append(matches, child.subs...)
+	}
+	if child, ok := t.children[segments[0]]; ok {
+		matches = child.match(segments[1:], matches)
+	}
+	return matches
+}
+
+func channelSegments(channel Channel) []string {
+	return strings.Split(strings.TrimPrefix(string(channel), "/"), "/")
+}
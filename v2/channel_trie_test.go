@@ -0,0 +1,71 @@
+package gobayeux
+
+import "testing"
+
+func TestChannelTrie_Match(t *testing.T) {
+	subscriptions := []Channel{"/foo", "/foo/*", "/foo/**", "/foo/bar", "/foo/bar/*", "/**", "/baz/*"}
+	trie := newChannelTrie()
+	subscribers := make(map[*subscriber]Channel)
+	for _, channel := range subscriptions {
+		s := newSubscriber(make(chan []Message))
+		subscribers[s] = channel
+		trie.Insert(channel, []*subscriber{s})
+	}
+
+	testCases := []struct {
+		channel  Channel
+		expected []Channel
+	}{
+		{"/foo", []Channel{"/**", "/foo"}},
+		{"/foo/bar", []Channel{"/**", "/foo/**", "/foo/*", "/foo/bar"}},
+		{"/foo/bar/baz", []Channel{"/**", "/foo/**", "/foo/bar/*"}},
+		{"/foo/bar/baz/qux", []Channel{"/**", "/foo/**"}},
+		{"/baz", []Channel{"/**"}},
+		{"/baz/qux", []Channel{"/**", "/baz/*"}},
+		{"/qux/foo", []Channel{"/**"}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(string(tc.channel), func(t *testing.T) {
+			matches := trie.Match(tc.channel)
+			got := make(map[Channel]bool)
+			for _, s := range matches {
+				got[subscribers[s]] = true
+			}
+			if len(matches) != len(tc.expected) {
+				t.Errorf("expected %d matches but got %d", len(tc.expected), len(matches))
+			}
+			for _, channel := range tc.expected {
+				if !got[channel] {
+					t.Errorf("expected %s to match %s", channel, tc.channel)
+				}
+				if !channel.Match(tc.channel) {
+					t.Errorf("Channel.Match disagrees that %s matches %s", channel, tc.channel)
+				}
+			}
+		})
+	}
+}
+
+func TestChannelTrie_Delete(t *testing.T) {
+	trie := newChannelTrie()
+	trie.Insert("/foo/*", []*subscriber{newSubscriber(nil)})
+	trie.Insert("/foo/bar/baz", []*subscriber{newSubscriber(nil)})
+
+	trie.Delete("/foo/bar/baz")
+	if matches := trie.Match("/foo/bar/baz"); len(matches) != 0 {
+		t.Errorf("expected no matches after delete but got %d", len(matches))
+	}
+	if _, ok := trie.children["foo"].children["bar"]; ok {
+		t.Error("expected empty nodes to be pruned")
+	}
+	if matches := trie.Match("/foo/bar"); len(matches) != 1 {
+		t.Errorf("expected /foo/* to still match but got %d matches", len(matches))
+	}
+
+	trie.Delete("/foo/*")
+	if len(trie.children) != 0 {
+		t.Error("expected every node to be pruned")
+	}
+}
@@ -2,7 +2,10 @@ package gobayeux
 
 import (
 	"context"
+	"errors"
 	"net/http"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -12,26 +15,68 @@ import (
 type Client struct {
 	client                    *BayeuxClient
 	subscriptions             *subscriptionsMap
+	dispatcher                *dispatcher
+	calls                     *pendingCalls
 	logger                    Logger
 	subscribeRequestChannel   chan subscriptionRequest
-	unsubscribeRequestChannel chan Channel
+	unsubscribeRequestChannel chan unsubscriptionRequest
 	connectRequestChannel     chan struct{}
-	connectMessageChannel     chan []Message
 	handshakeRequestChannel   chan struct{}
 	shutdown                  chan struct{}
 	ignoreError               IgnoreErrorFunc
+	publishLock               sync.Mutex
+	shutdownOnce              sync.Once
+	backoff                   BackoffPolicy
+	reauthenticator           Reauthenticator
+	// deferredSubscriptions are waiting for the server's reply to a
+	// subscription that will be sent after the next handshake. They are
+	// only accessed by the polling goroutine.
+	deferredSubscriptions []subscriptionRequest
 }
 
+// errorsBufferSize is the number of errors the client recovered from which
+// may wait to be received from the channel returned by Start
+const errorsBufferSize = 10
+
 // IgnoreErrorFunc is a callback function that inspects an error and determines
 // if it can be safely ignored when subscribing and unsubscribing.
 type IgnoreErrorFunc func(error) bool
 
+// Reauthenticator renews the credentials a Client authenticates with. The
+// http.RoundTripper passed to WithHTTPTransport is used as the Client's
+// Reauthenticator when it implements this interface.
+type Reauthenticator interface {
+	// Reauthenticate is called with the error a request failed with and
+	// any replies received. It returns true once new credentials are in
+	// place, after which the Client handshakes again, or false when err is
+	// not an authentication failure or the credentials could not be
+	// renewed.
+	Reauthenticate(ctx context.Context, replies []Message, err error) bool
+}
+
 // Options stores the available configuration options for a Client
 type Options struct {
 	Logger      Logger
 	Client      *http.Client
 	Transport   http.RoundTripper
 	IgnoreError IgnoreErrorFunc
+	WebSocket   bool
+	// BayeuxTransports are registered with the BayeuxClient in order. See
+	// also BayeuxClient.UseTransport
+	BayeuxTransports []Transport
+	// Backoff determines how transient failures are retried. When nil,
+	// they are returned immediately.
+	Backoff BackoffPolicy
+	// AlternateServers are failed over to, in order, when the server
+	// passed to NewClient cannot be reached. See also
+	// BayeuxClient.AddServerAddress
+	AlternateServers []string
+	// Reauthenticator renews credentials the server has rejected. When
+	// nil, Transport is used if it implements Reauthenticator.
+	Reauthenticator Reauthenticator
+	// IDGenerator generates the id of every message sent. When nil, a
+	// CounterIDGenerator is used. See also BayeuxClient.UseIDGenerator
+	IDGenerator IDGenerator
 }
 
 // Option defines the type passed into NewClient for configuration
@@ -76,6 +121,66 @@ func WithIgnoreError(f IgnoreErrorFunc) Option {
 	}
 }
 
+// WithWebSocket returns an Option that prefers the websocket connection type
+// over long-polling. The connection type is negotiated during the handshake
+// and the client falls back to long-polling when the server does not support
+// websockets.
+func WithWebSocket() Option {
+	return func(options *Options) {
+		options.WebSocket = true
+	}
+}
+
+// WithBayeuxTransport returns an Option that registers an additional
+// Transport, such as a callback-polling or in-process implementation. It may
+// be passed multiple times; transports are preferred in the order given.
+//
+// See also: BayeuxClient.UseTransport
+func WithBayeuxTransport(t Transport) Option {
+	return func(options *Options) {
+		options.BayeuxTransports = append(options.BayeuxTransports, t)
+	}
+}
+
+// WithBackoff returns an Option that retries handshake and /meta/connect
+// requests which fail for transient reasons, such as network errors or 5xx
+// responses, according to policy. Once the policy's MaxElapsedTime has passed
+// the error is returned as usual. The policy also spaces out the retries the
+// server advises after refusing a request, on top of the advised interval.
+//
+// The default is to return these errors immediately.
+func WithBackoff(policy BackoffPolicy) Option {
+	return func(options *Options) {
+		options.Backoff = policy
+	}
+}
+
+// WithAlternateServers returns an Option that adds servers to fail over to
+// when the server passed to NewClient cannot be reached. Servers are tried in
+// order and the client handshakes with the new server and resubscribes to
+// every active channel, while registered extensions are kept. Once every
+// server has been tried, the BackoffPolicy chosen with WithBackoff decides
+// how long to wait before trying them again.
+func WithAlternateServers(serverAddresses ...string) Option {
+	return func(options *Options) {
+		options.AlternateServers = append(options.AlternateServers, serverAddresses...)
+	}
+}
+
+// WithReauthenticator returns an Option with reauthenticator
+func WithReauthenticator(reauthenticator Reauthenticator) Option {
+	return func(options *Options) {
+		options.Reauthenticator = reauthenticator
+	}
+}
+
+// WithIDGenerator returns an Option with ids
+func WithIDGenerator(ids IDGenerator) Option {
+	return func(options *Options) {
+		options.IDGenerator = ids
+	}
+}
+
 // NewClient creates a new high-level client
 func NewClient(serverAddress string, opts ...Option) (*Client, error) {
 	options := &Options{}
@@ -97,45 +202,139 @@ func NewClient(serverAddress string, opts ...Option) (*Client, error) {
 		}
 	}
 
+	if r, ok := options.Transport.(Reauthenticator); ok && options.Reauthenticator == nil {
+		options.Reauthenticator = r
+	}
+
 	bc, err := NewBayeuxClient(options.Client, options.Transport, serverAddress, options.Logger)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{
+	for _, serverAddress := range options.AlternateServers {
+		if err := bc.AddServerAddress(serverAddress); err != nil {
+			return nil, err
+		}
+	}
+
+	if options.IDGenerator != nil {
+		bc.UseIDGenerator(options.IDGenerator)
+	}
+
+	if options.WebSocket {
+		bc.useWebSocket()
+	}
+
+	for _, t := range options.BayeuxTransports {
+		if err := bc.UseTransport(t); err != nil {
+			return nil, err
+		}
+	}
+
+	subscriptions := newSubscriptionsMap()
+	shutdown := make(chan struct{})
+	c := &Client{
 		client:                    bc,
-		subscriptions:             newSubscriptionsMap(),
+		subscriptions:             subscriptions,
+		dispatcher:                newDispatcher(subscriptions, options.Logger, shutdown),
+		calls:                     newPendingCalls(),
 		subscribeRequestChannel:   make(chan subscriptionRequest, 10),
-		unsubscribeRequestChannel: make(chan Channel, 10),
+		unsubscribeRequestChannel: make(chan unsubscriptionRequest, 10),
 		connectRequestChannel:     make(chan struct{}, 1),
-		connectMessageChannel:     make(chan []Message, 5),
-		handshakeRequestChannel:   make(chan struct{}),
-		shutdown:                  make(chan struct{}),
+		handshakeRequestChannel:   make(chan struct{}, 1),
+		shutdown:                  shutdown,
 		logger:                    options.Logger,
 		ignoreError:               options.IgnoreError,
-	}, nil
+		backoff:                   options.Backoff,
+		reauthenticator:           options.Reauthenticator,
+	}
+	c.dispatcher.Claim(c.calls.Resolve)
+	return c, nil
 }
 
-// Subscribe queues a request to subscribe to a new channel from the server
-func (c *Client) Subscribe(ch Channel, receiving chan []Message) {
-	c.subscribeRequestChannel <- subscriptionRequest{ch, receiving}
+// Subscribe queues a request to subscribe to a new channel from the server.
+// Messages are delivered to receiving in batches, waiting for each to be
+// received unless another DeliveryPolicy is chosen with WithDeliveryPolicy.
+// The request is dropped once the client has been disconnected.
+func (c *Client) Subscribe(ch Channel, receiving chan []Message, opts ...SubscribeOption) {
+	select {
+	case c.subscribeRequestChannel <- subscriptionRequest{ch, newChannelSubscriber(receiving, opts), nil}:
+	case <-c.shutdown:
+	}
 }
 
 // SubscribeWithContext queues a request to subscribe to a new channel from the server.
 // It respects the provided context and will return an error if the context is cancelled
 // before the subscription request can be queued.
-func (c *Client) SubscribeWithContext(ctx context.Context, ch Channel, receiving chan []Message) error {
+func (c *Client) SubscribeWithContext(ctx context.Context, ch Channel, receiving chan []Message, opts ...SubscribeOption) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case c.subscribeRequestChannel <- subscriptionRequest{ch, receiving}:
+	case c.subscribeRequestChannel <- subscriptionRequest{ch, newChannelSubscriber(receiving, opts), nil}:
 		return nil
+	case <-c.shutdown:
+		return ErrClientNotConnected
+	}
+}
+
+// SubscribeAndWait subscribes to ch and waits for the server's reply. If the
+// server rejects the subscription, the returned SubscriptionResult includes
+// the parsed error and a SubscriptionFailedError is returned. If another
+// subscriber is already subscribed to ch, it returns successfully without
+// asking the server again.
+//
+// Subscription requests are batched by the polling task started by Start, so
+// when ctx expires the request may still be sent and only the wait is
+// abandoned.
+func (c *Client) SubscribeAndWait(ctx context.Context, ch Channel, receiving chan []Message, opts ...SubscribeOption) (SubscriptionResult, error) {
+	req := subscriptionRequest{ch, newChannelSubscriber(receiving, opts), make(chan subscriptionResponse, 1)}
+	select {
+	case <-ctx.Done():
+		return SubscriptionResult{Channel: ch}, ctx.Err()
+	case c.subscribeRequestChannel <- req:
+	case <-c.shutdown:
+		return SubscriptionResult{Channel: ch}, ErrClientNotConnected
+	}
+	return c.wait(ctx, ch, req.response)
+}
+
+// UnsubscribeAndWait unsubscribes from ch and waits for the server's reply.
+// If the server rejects the request, the returned SubscriptionResult
+// includes the parsed error and an UnsubscribeFailedError is returned.
+//
+// As with SubscribeAndWait, when ctx expires only the wait is abandoned.
+func (c *Client) UnsubscribeAndWait(ctx context.Context, ch Channel) (SubscriptionResult, error) {
+	req := unsubscriptionRequest{ch, nil, make(chan subscriptionResponse, 1)}
+	select {
+	case <-ctx.Done():
+		return SubscriptionResult{Channel: ch}, ctx.Err()
+	case c.unsubscribeRequestChannel <- req:
+	case <-c.shutdown:
+		return SubscriptionResult{Channel: ch}, ErrClientNotConnected
+	}
+	return c.wait(ctx, ch, req.response)
+}
+
+func (c *Client) wait(ctx context.Context, ch Channel, response <-chan subscriptionResponse) (SubscriptionResult, error) {
+	select {
+	case r := <-response:
+		return r.result, r.err
+	case <-ctx.Done():
+		return SubscriptionResult{Channel: ch}, ctx.Err()
+	case <-c.shutdown:
+		return SubscriptionResult{Channel: ch}, ErrClientNotConnected
 	}
 }
 
-// Unsubscribe queues a request to unsubscribe from a channel on the server
+// Unsubscribe queues a request to unsubscribe from a channel on the server.
+// Every listener on the channel, including those registered with On, stops
+// receiving messages. The request is dropped once the client has been
+// disconnected.
 func (c *Client) Unsubscribe(ch Channel) {
-	c.unsubscribeRequestChannel <- ch
+	select {
+	case c.unsubscribeRequestChannel <- unsubscriptionRequest{ch, nil, nil}:
+	case <-c.shutdown:
+	}
 }
 
 // UnsubscribeWithContext queues a request to unsubscribe from a channel on the server.
@@ -145,41 +344,60 @@ func (c *Client) UnsubscribeWithContext(ctx context.Context, ch Channel) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
-	case c.unsubscribeRequestChannel <- ch:
+	case c.unsubscribeRequestChannel <- unsubscriptionRequest{ch, nil, nil}:
 		return nil
+	case <-c.shutdown:
+		return ErrClientNotConnected
+	}
+}
+
+// DeliveryStats returns the counters for the subscription to ch which
+// delivers to receiving, if there is one
+func (c *Client) DeliveryStats(ch Channel, receiving chan []Message) (DeliveryStats, bool) {
+	s, ok := c.subscriptions.Find(ch, receiving)
+	if !ok {
+		return DeliveryStats{}, false
 	}
+	return s.stats(), true
 }
 
-// Start begins the background process that talks to the server
+// Start begins the background process that talks to the server. The error
+// that stops it is sent on the returned channel. Errors it recovers from,
+// such as failing to resubscribe after a re-handshake, are sent as well but
+// are dropped rather than waited on once the channel's buffer is full.
 func (c *Client) Start(ctx context.Context) <-chan error {
-	errors := make(chan error)
+	errors := make(chan error, errorsBufferSize)
 	go c.start(ctx, errors)
 	return errors
 }
 
 // Disconnect issues a /meta/disconnect request to the Bayeux server and then
-// cleans up channels and our timer.
+// stops the background process started by Start. Once it has been called,
+// further calls return ErrClientNotConnected.
 func (c *Client) Disconnect(ctx context.Context) error {
-	_, err := c.client.Disconnect(ctx)
-	close(c.subscribeRequestChannel)
-	close(c.unsubscribeRequestChannel)
-	close(c.connectRequestChannel)
-	close(c.connectMessageChannel)
-	close(c.handshakeRequestChannel)
+	var err error = DisconnectFailedError{ErrClientNotConnected}
+	c.shutdownOnce.Do(func() {
+		_, err = c.client.Disconnect(ctx)
+		close(c.shutdown)
+	})
 	return err
 }
 
-// Publish is not yet implemented. When implemented, it will - in a separate thread
-// from the polling task - publish messages to the Bayeux Server.
+// Publish sends messages to the Bayeux Server. It runs independently of the
+// polling task started by Start so that publishing is never blocked by an
+// outstanding /meta/connect request. Only one publish request is outstanding
+// at a time; concurrent callers wait for their turn.
+//
+// If the server rejects any of the messages, a PublishFailedError is
+// returned.
 //
 // See also: https://docs.cometd.org/current/reference/#_two_connection_operation
 func (c *Client) Publish(ctx context.Context, messages []Message) error {
-	// TODO:
-	// * Locking mechanism to ensure only one outstanding Publish request at a
-	//   time
-	// * Ensure that this separate from Start()/poll()
-	// * Implement Publish() in *BayeuxClient
-	panic("Publish() is not yet implemented")
+	c.publishLock.Lock()
+	defer c.publishLock.Unlock()
+
+	_, err := c.client.Publish(ctx, messages)
+	return err
 }
 
 // UseExtension adds the provided MessageExtender as an extension for use with
@@ -190,29 +408,161 @@ func (c *Client) UseExtension(ext MessageExtender) error {
 	return c.client.UseExtension(ext)
 }
 
+// AddExtension adds the provided Extension for use with this Client
+// session. Unlike a MessageExtender, an Extension may drop messages or fail
+// requests.
+func (c *Client) AddExtension(ext Extension) error {
+	return c.client.AddExtension(ext)
+}
+
+// RemoveExtension removes an extension previously added with UseExtension or
+// AddExtension. Messages sent or received afterwards no longer pass through
+// it.
+func (c *Client) RemoveExtension(ext ExtensionLifecycle) error {
+	return c.client.RemoveExtension(ext)
+}
+
 func (c *Client) start(ctx context.Context, errors chan error) {
 	logger := c.logger.WithField("at", "start")
-	if _, err := c.client.Handshake(ctx); err != nil {
+	if err := c.handshake(ctx); err != nil {
 		errors <- err
 		return
 	}
 
-	_ = c.subscriptions.Add(MetaConnect, c.connectMessageChannel)
-
 	logger.Debug("starting long-polling loop")
+	c.enqueueConnectRequest()
 	if err := c.poll(ctx, errors); err != nil {
 		errors <- err
 		return
 	}
+}
 
-	if _, err := c.client.Disconnect(ctx); err != nil {
-		errors <- err
+// handshake performs a handshake with the server, retrying for as long as the
+// server advises us to do so in its reply and our advice backoff allows.
+// Transient failures move on to the next server we know of, and once every
+// server has been tried we retry for as long as our BackoffPolicy allows.
+//
+// See also: https://docs.cometd.org/current/reference/#_client_state_table
+func (c *Client) handshake(ctx context.Context) error {
+	logger := c.logger.WithField("at", "handshake")
+	retries := newRetrier(c.backoff)
+	adviceRetries := newRetrier(c.adviceBackoff())
+	failovers := 0
+	reauthenticated := false
+	for {
+		ms, err := c.client.Handshake(ctx)
+		if err == nil {
+			return nil
+		}
+		// Only renew credentials once so that credentials which are
+		// rejected as soon as they are issued do not loop forever
+		if !reauthenticated && c.reauthenticate(ctx, ms, err) {
+			reauthenticated = true
+			continue
+		}
+
+		var interval time.Duration
+		if advice := adviceFrom(ms); advice != nil {
+			// Both retry and handshake advice mean handshaking again
+			// once the interval has passed
+			if advice.MustNotRetryOrHandshake() {
+				return err
+			}
+			delay, ok := adviceRetries.next()
+			if !ok {
+				return err
+			}
+			interval = advice.IntervalAsDuration() + delay
+			logger.WithError(err).WithField("interval", interval).Debug("retrying handshake per advice")
+		} else if !isTransient(ctx, err) {
+			return err
+		} else if failovers < c.client.serverCount()-1 {
+			failovers++
+			c.client.failover()
+			logger.WithError(err).Debug("retrying handshake with the next server")
+		} else if delay, ok := retries.next(); ok {
+			failovers = 0
+			c.client.failover()
+			interval = delay
+			logger.WithError(err).WithField("interval", interval).Debug("retrying handshake after transient failure")
+		} else {
+			return err
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-c.shutdown:
+			return err
+		}
+	}
+}
+
+// followAdvice schedules what happens after a /meta/connect request according
+// to the most recent advice from the server. When the server refused the
+// request with connectErr but advised us to retry, the advised interval is
+// extended by the next delay of retries, and connectErr is returned once
+// retries gives up.
+//
+// See also: https://docs.cometd.org/current/reference/#_client_state_table
+func (c *Client) followAdvice(ctx context.Context, retries *retrier, connectErr error) error {
+	advice := c.client.Advice()
+	interval := advice.IntervalAsDuration()
+	if connectErr == nil {
+		retries.reset()
+	} else if !advice.MustNotRetryOrHandshake() && !advice.ShouldHandshake() {
+		delay, ok := retries.next()
+		if !ok {
+			return connectErr
+		}
+		interval += delay
+	}
+	logger := c.logger.WithField("at", "followAdvice").WithField("reconnect", advice.Reconnect).WithField("interval", interval)
+	switch {
+	case advice.MustNotRetryOrHandshake():
+		logger.Debug("stopping per advice")
+		return ErrMustNotReconnect
+	case advice.ShouldHandshake():
+		logger.Debug("handshaking per advice")
+		c.after(ctx, interval, c.enqueueHandshakeRequest)
+	default:
+		logger.Debug("connecting per advice")
+		c.after(ctx, interval, c.enqueueConnectRequest)
+	}
+	return nil
+}
+
+// adviceBackoff returns the BackoffPolicy for the retries the server advises
+// after refusing a request, which defaults to defaultAdviceBackoff
+func (c *Client) adviceBackoff() BackoffPolicy {
+	if c.backoff == nil {
+		return defaultAdviceBackoff
+	}
+	return c.backoff
+}
+
+// after calls f once d has elapsed unless the client is stopped first
+func (c *Client) after(ctx context.Context, d time.Duration, f func()) {
+	if d <= 0 {
+		f()
 		return
 	}
+
+	go func() {
+		select {
+		case <-time.After(d):
+			f()
+		case <-ctx.Done():
+		case <-c.shutdown:
+		}
+	}()
 }
 
 func (c *Client) poll(ctx context.Context, errors chan<- error) error {
 	logger := c.logger.WithField("at", "poll")
+	connectRetries := newRetrier(c.backoff)
+	adviceRetries := newRetrier(c.adviceBackoff())
 _poll_loop:
 	for {
 		logger.Debug("in polling loop")
@@ -230,124 +580,164 @@ _poll_loop:
 		case subReq := <-c.subscribeRequestChannel:
 			logger.Debug("got subscription requests")
 			// Let's attempt to drain the channel before sending a
-			// /meta/unsubscribe request to more efficiently use HTTP
+			// /meta/subscribe request to more efficiently use HTTP
 			// requests
-			subReqs, channels := c.getSubscriptionRequests()
-			subReqs = append(subReqs, subReq)
-			channels = append(channels, subReq.subscription)
-			// TODO: Find a way to consolidate this logic and the logic in
-			// start()
-			if _, err := c.client.Subscribe(ctx, channels); err != nil {
-				if c.ignoreError(err) {
-					errors <- err
-					continue
-				}
-
-				return err
-			}
-
+			subReqs := append(c.getSubscriptionRequests(), subReq)
+			// Only the first subscriber to a channel needs to tell the
+			// server about it
+			channels := make([]Channel, 0, len(subReqs))
+			pending := make([]subscriptionRequest, 0, len(subReqs))
 			for _, subReq := range subReqs {
-				if err := c.subscriptions.Add(subReq.subscription, subReq.msgChan); err != nil {
+				first, err := c.subscriptions.Add(subReq.subscription, subReq.subscriber)
+				if err != nil {
+					if subReq.respond(SubscriptionResult{Channel: subReq.subscription}, err) {
+						continue
+					}
 					if c.ignoreError(err) {
-						errors <- err
+						c.report(errors, err)
 						continue
 					}
 
 					return err
 				}
+				if !first {
+					subReq.respond(SubscriptionResult{Channel: subReq.subscription, Successful: true}, nil)
+					continue
+				}
+				if !slices.Contains(channels, subReq.subscription) {
+					channels = append(channels, subReq.subscription)
+				}
+				pending = append(pending, subReq)
+			}
+			if len(channels) == 0 {
+				continue
 			}
 
-			c.enqueueConnectRequest()
+			// TODO: Find a way to consolidate this logic and the logic in
+			// start()
+			ms, err := c.subscribe(ctx, channels)
+			if isNotConnected(err) {
+				// We are between sessions, e.g., while failing over,
+				// so these are subscribed to with the rest after the
+				// next handshake
+				logger.Debug("deferring subscriptions until the next handshake")
+				c.deferredSubscriptions = append(c.deferredSubscriptions, pending...)
+				continue
+			}
+			if err != nil {
+				for _, channel := range channels {
+					if !isSubscribed(ms, channel) {
+						c.subscriptions.Remove(channel)
+					}
+				}
+			}
+			if answered := respondToSubscriptions(pending, ms, err); err != nil && !answered {
+				if c.ignoreError(err) {
+					c.report(errors, err)
+					continue
+				}
+
+				return err
+			}
 
 		case unsubReq := <-c.unsubscribeRequestChannel:
 			logger.Debug("got unsubscribe requests")
-			channels := c.getUnsubscriptionRequests()
-			channels = append(channels, unsubReq)
-			if _, err := c.client.Unsubscribe(ctx, channels); err != nil {
+			unsubReqs := append(c.getUnsubscriptionRequests(), unsubReq)
+			// Only once the last subscriber to a channel leaves do we
+			// tell the server
+			channels := make([]Channel, 0, len(unsubReqs))
+			pending := make([]subscriptionRequest, 0, len(unsubReqs))
+			for _, unsubReq := range unsubReqs {
+				if unsubReq.subscriber == nil {
+					c.subscriptions.Remove(unsubReq.subscription)
+				} else if removed, remaining := c.subscriptions.RemoveSubscriber(unsubReq.subscription, unsubReq.subscriber); !removed || remaining > 0 {
+					unsubReq.respond(SubscriptionResult{Channel: unsubReq.subscription, Successful: true}, nil)
+					continue
+				}
+				if !slices.Contains(channels, unsubReq.subscription) {
+					channels = append(channels, unsubReq.subscription)
+				}
+				pending = append(pending, subscriptionRequest(unsubReq))
+			}
+			if len(channels) == 0 {
+				continue
+			}
+
+			ms, err := c.client.Unsubscribe(ctx, channels)
+			if answered := respondToUnsubscriptions(pending, ms, err); err != nil && !answered {
 				if c.ignoreError(err) {
-					errors <- err
+					c.report(errors, err)
 					continue
 				}
 
 				return err
 			}
 
-			for _, channel := range channels {
-				c.subscriptions.Remove(channel)
-			}
-
 		case <-c.handshakeRequestChannel:
-			logger.Debug("re-handshaking")
-			if _, err := c.client.Handshake(ctx); err != nil {
+			if err := c.rehandshake(ctx, errors); err != nil {
 				return err
 			}
 			c.enqueueConnectRequest()
-		case ms := <-c.connectMessageChannel:
-			logger.Debug("handling messages from /meta/connect")
-			for _, m := range ms {
-				if m.Advice.ShouldHandshake() {
-					logger.Debug("queueing new handshake request")
-					c.handshakeRequestChannel <- struct{}{}
-				}
-				interval := m.Advice.IntervalAsDuration()
-				go func() {
-					c.logger.WithField("interval", interval).Debug("waiting per advice")
-					<-time.After(interval)
-					c.enqueueConnectRequest()
-				}()
-			}
-
 		case <-c.connectRequestChannel:
 			logger.Debug("checking for new messages")
 			ms, err := c.client.Connect(ctx)
-			if err != nil {
-				logger.WithError(err).Debug("error in /meta/connect")
-				return err
+			if err != nil && c.reauthenticate(ctx, ms, err) {
+				// Our session belongs to the old credentials
+				c.enqueueHandshakeRequest()
+				continue
 			}
-			batch := make([]Message, 0)
-			lastChannel := emptyChannel
-			logger.Debug("delivering messages")
-			for _, m := range ms {
-				switch lastChannel {
-				case emptyChannel:
-					lastChannel = m.Channel
-					batch = append(batch, m)
-				case m.Channel:
-					batch = append(batch, m)
-				default:
-					msgChan, err := c.subscriptions.Get(lastChannel)
-					if err != nil {
-						return err
-					}
-					logger.WithField("channel", lastChannel).Debug("sending batch")
-					msgChan <- batch
-					lastChannel = m.Channel
-					batch = append([]Message(nil), m)
+			if err != nil && !isUnsuccessfulConnect(err) {
+				logger.WithError(err).Debug("error in /meta/connect")
+				if !isTransient(ctx, err) {
+					return err
+				}
+				if c.client.serverCount() > 1 {
+					// The server is gone so try the next one,
+					// carrying our subscriptions over with a new
+					// handshake. Like any other, it tries each
+					// remaining server before backing off.
+					connectRetries.reset()
+					c.client.failover()
+					c.enqueueHandshakeRequest()
+					continue
+				}
+				delay, ok := connectRetries.next()
+				if !ok {
+					return err
 				}
+				logger.WithField("delay", delay).Debug("retrying /meta/connect after transient failure")
+				c.after(ctx, delay, c.enqueueConnectRequest)
+				continue
 			}
+			connectRetries.reset()
+			logger.Debug("delivering messages")
+			// The dispatcher ignores the /meta/connect reply. Its
+			// advice was recorded by the BayeuxClient and is followed
+			// below, even when a websocket response holds deliveries
+			// without the reply.
+			c.dispatcher.Dispatch(ms)
 
-		default:
-			c.enqueueConnectRequest()
+			if err := c.followAdvice(ctx, adviceRetries, err); err != nil {
+				return err
+			}
 		}
 	}
 	return nil
 }
 
-func (c *Client) getSubscriptionRequests() ([]subscriptionRequest, []Channel) {
+func (c *Client) getSubscriptionRequests() []subscriptionRequest {
 	subscriptionRequests := make([]subscriptionRequest, 0)
-	channels := make([]Channel, 0)
 
 _get_subs_for_loop:
 	for {
 		select {
 		case req := <-c.subscribeRequestChannel:
 			subscriptionRequests = append(subscriptionRequests, req)
-			channels = append(channels, req.subscription)
 		default:
 			break _get_subs_for_loop
 		}
 	}
-	return subscriptionRequests, channels
+	return subscriptionRequests
 }
 
 func (c *Client) enqueueConnectRequest() {
@@ -360,8 +750,121 @@ func (c *Client) enqueueConnectRequest() {
 	}
 }
 
-func (c *Client) getUnsubscriptionRequests() []Channel {
-	unsubscriptionRequests := make([]Channel, 0)
+func (c *Client) enqueueHandshakeRequest() {
+	logger := c.logger.WithField("at", "enqueueHandshakeRequest")
+	select {
+	case c.handshakeRequestChannel <- struct{}{}:
+		logger.Debug("queued next /meta/handshake request")
+	default:
+		logger.Debug("/meta/handshake request queue full")
+	}
+}
+
+// reauthenticate asks our Reauthenticator to renew the credentials rejected
+// with err and, when it does, ends our session so that a new one can be
+// established with them
+func (c *Client) reauthenticate(ctx context.Context, replies []Message, err error) bool {
+	if c.reauthenticator == nil || !c.reauthenticator.Reauthenticate(ctx, replies, err) {
+		return false
+	}
+	c.logger.WithField("at", "reauthenticate").WithError(err).Debug("renewed credentials")
+	c.client.endSession()
+	return true
+}
+
+// rehandshake establishes a new session with the server and then replays
+// every active subscription since the server forgets them along with the old
+// session. Failing to resubscribe is reported on errors rather than stopping
+// the client so that the remaining subscriptions keep working. Calls waiting
+// for a reply are abandoned as the server forgets them too.
+func (c *Client) rehandshake(ctx context.Context, errors chan<- error) error {
+	c.logger.WithField("at", "rehandshake").Debug("re-handshaking")
+	c.calls.Abandon()
+	if err := c.handshake(ctx); err != nil {
+		return err
+	}
+
+	for _, err := range c.resubscribe(ctx) {
+		c.report(errors, err)
+	}
+	return nil
+}
+
+// report sends err, which the polling task recovered from, on errors without
+// waiting for it to be received
+func (c *Client) report(errors chan<- error, err error) {
+	select {
+	case errors <- err:
+	default:
+		c.logger.WithError(err).Debug("dropping error as nobody is receiving")
+	}
+}
+
+// resubscribe subscribes to every active channel using a single batched
+// /meta/subscribe request and returns an error for each channel the server
+// did not accept
+func (c *Client) resubscribe(ctx context.Context) []error {
+	channels := c.subscriptions.Channels()
+	if len(channels) == 0 {
+		return nil
+	}
+
+	logger := c.logger.WithField("at", "resubscribe").WithField("channels", channels)
+	logger.Debug("resubscribing")
+	ms, err := c.subscribe(ctx, channels)
+	if deferred := c.deferredSubscriptions; len(deferred) > 0 {
+		c.deferredSubscriptions = nil
+		respondToSubscriptions(deferred, ms, err)
+	}
+	if err == nil {
+		return nil
+	}
+
+	logger.WithError(err).Debug("error resubscribing")
+	errs := make([]error, 0)
+	for _, m := range ms {
+		if m.Channel == MetaSubscribe && !m.Successful {
+			errs = append(errs, SubscriptionFailedError{
+				Channels: []Channel{m.Subscription},
+				Err:      newSubscribeError(m),
+			})
+		}
+	}
+	if len(errs) == 0 {
+		// The request itself failed so none of the channels were
+		// resubscribed
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// subscribe sends a /meta/subscribe request for channels. When an extension
+// asks for some of the rejected channels to be subscribed to again, e.g.
+// from another replay position, a second request is sent for those with the
+// same ctx and its replies replace theirs.
+func (c *Client) subscribe(ctx context.Context, channels []Channel) ([]Message, error) {
+	ms, err := c.client.Subscribe(ctx, channels)
+	retries := c.client.takeRetries(channels)
+	if err == nil || len(retries) == 0 {
+		return ms, err
+	}
+
+	c.logger.WithField("at", "subscribe").WithField("channels", retries).Debug("subscribing again as asked by an extension")
+	retried, retryErr := c.client.Subscribe(ctx, retries)
+	ms = slices.DeleteFunc(ms, func(m Message) bool {
+		return m.Channel == MetaSubscribe && slices.Contains(retries, m.Subscription)
+	})
+	ms = append(ms, retried...)
+	for _, m := range ms {
+		if m.Channel == MetaSubscribe && !m.Successful {
+			return ms, SubscriptionFailedError{channels, newSubscribeError(m)}
+		}
+	}
+	return ms, retryErr
+}
+
+func (c *Client) getUnsubscriptionRequests() []unsubscriptionRequest {
+	unsubscriptionRequests := make([]unsubscriptionRequest, 0)
 
 _get_unsubs_for_loop:
 	for {
@@ -377,5 +880,127 @@ _get_unsubs_for_loop:
 
 type subscriptionRequest struct {
 	subscription Channel
-	msgChan      chan []Message
+	subscriber   *subscriber
+	// response receives the server's reply when the caller is waiting
+	// for it
+	response chan subscriptionResponse
+}
+
+// respond sends the outcome of the request to the caller if it is waiting
+// for it and reports whether it was
+func (r subscriptionRequest) respond(result SubscriptionResult, err error) bool {
+	if r.response == nil {
+		return false
+	}
+	r.response <- subscriptionResponse{result, err}
+	return true
+}
+
+// unsubscriptionRequest removes subscriber from subscription or, when
+// subscriber is nil, every subscriber
+type unsubscriptionRequest struct {
+	subscription Channel
+	subscriber   *subscriber
+	response     chan subscriptionResponse
+}
+
+func (r unsubscriptionRequest) respond(result SubscriptionResult, err error) bool {
+	return subscriptionRequest(r).respond(result, err)
+}
+
+type subscriptionResponse struct {
+	result SubscriptionResult
+	err    error
+}
+
+// respondToSubscriptions answers every request waiting for the reply ms to a
+// /meta/subscribe request and reports whether all of them were waiting
+func respondToSubscriptions(reqs []subscriptionRequest, ms []Message, err error) bool {
+	answered := true
+	for _, req := range reqs {
+		result, err := subscriptionResult(ms, MetaSubscribe, req.subscription, err)
+		if err != nil {
+			err = SubscriptionFailedError{[]Channel{req.subscription}, err}
+		}
+		answered = req.respond(result, err) && answered
+	}
+	return answered
+}
+
+// respondToUnsubscriptions is respondToSubscriptions for /meta/unsubscribe
+func respondToUnsubscriptions(reqs []subscriptionRequest, ms []Message, err error) bool {
+	answered := true
+	for _, req := range reqs {
+		result, err := subscriptionResult(ms, MetaUnsubscribe, req.subscription, err)
+		if err != nil {
+			err = UnsubscribeFailedError{[]Channel{req.subscription}, err}
+		}
+		answered = req.respond(result, err) && answered
+	}
+	return answered
+}
+
+// subscriptionResult finds the server's reply for channel in ms, the
+// response to a request on metaChannel that failed with err, if at all
+func subscriptionResult(ms []Message, metaChannel, channel Channel, err error) (SubscriptionResult, error) {
+	result := SubscriptionResult{Channel: channel}
+	for _, m := range ms {
+		if m.Channel != metaChannel || m.Subscription != channel {
+			continue
+		}
+
+		result.Successful = m.Successful
+		if m.Successful {
+			return result, nil
+		}
+		if messageError, parseErr := m.ParseError(); parseErr == nil {
+			result.Error = &messageError
+		}
+		if metaChannel == MetaSubscribe {
+			return result, newSubscribeError(m)
+		}
+		return result, newUnsubscribeError(m)
+	}
+
+	// Without a reply of its own, the channel shares the fate of the
+	// request as a whole
+	if err != nil {
+		if inner := errors.Unwrap(err); inner != nil {
+			err = inner
+		}
+		return result, err
+	}
+	result.Successful = true
+	return result, nil
+}
+
+// isUnsuccessfulConnect reports whether err is the result of the server
+// replying unsuccessfully to /meta/connect, in which case its advice should
+// be followed, rather than the request itself failing
+func isUnsuccessfulConnect(err error) bool {
+	return errors.Is(err, ErrFailedToConnect)
+}
+
+// isNotConnected reports whether err is due to the client not having a
+// session with the server
+func isNotConnected(err error) bool {
+	return errors.Is(err, ErrClientNotConnected)
+}
+
+// isSubscribed reports whether the server accepted the subscription to
+// channel in its reply ms
+func isSubscribed(ms []Message, channel Channel) bool {
+	return slices.ContainsFunc(ms, func(m Message) bool {
+		return m.Channel == MetaSubscribe && m.Subscription == channel && m.Successful
+	})
+}
+
+// adviceFrom returns the first advice included in ms, if any
+func adviceFrom(ms []Message) *Advice {
+	for _, m := range ms {
+		if m.Advice != nil {
+			return m.Advice
+		}
+	}
+	return nil
 }
@@ -0,0 +1,112 @@
+package gobayeux
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+// newResubscribeTransport replies to handshakes and to subscriptions, which
+// are accepted for every channel other than /foo/baz
+func newResubscribeTransport() *fakeTransport {
+	return &fakeTransport{
+		connectionType: ConnectionTypeLongPolling,
+		reply: func(ms []Message) []Message {
+			replies := make([]Message, 0, len(ms))
+			for _, m := range ms {
+				switch m.Channel {
+				case MetaHandshake:
+					replies = append(replies, Message{
+						Channel:                  MetaHandshake,
+						ClientID:                 "Un1q31d3nt1f13r",
+						SupportedConnectionTypes: []string{ConnectionTypeLongPolling},
+						Successful:               true,
+					})
+				case MetaSubscribe:
+					reply := Message{Channel: MetaSubscribe, Subscription: m.Subscription, Successful: true}
+					if m.Subscription == "/foo/baz" {
+						reply.Successful = false
+						reply.Error = "403::Subscription denied"
+					}
+					replies = append(replies, reply)
+				}
+			}
+			return replies
+		},
+	}
+}
+
+func TestClient_RehandshakeResubscribes(t *testing.T) {
+	transport := newResubscribeTransport()
+	c, err := NewClient("https://example.com", WithBayeuxTransport(transport))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	for _, channel := range []Channel{MetaConnect, "/foo/bar", "/foo/baz"} {
+		if _, err := c.subscriptions.Add(channel, newSubscriber(make(chan []Message))); err != nil {
+			t.Fatalf("failed to add subscription (%v)", err)
+		}
+	}
+
+	errs := make(chan error, 10)
+	if err := c.rehandshake(context.Background(), errs); err != nil {
+		t.Fatalf("expected rehandshake to succeed but got %q", err)
+	}
+	close(errs)
+
+	subscribes := make([][]Message, 0)
+	for _, batch := range transport.sent {
+		if batch[0].Channel == MetaSubscribe {
+			subscribes = append(subscribes, batch)
+		}
+	}
+	if len(subscribes) != 1 {
+		t.Fatalf("expected a single batched subscribe request, got %d", len(subscribes))
+	}
+	got := make([]Channel, 0)
+	for _, m := range subscribes[0] {
+		got = append(got, m.Subscription)
+	}
+	slices.Sort(got)
+	if want := []Channel{"/foo/bar", "/foo/baz"}; !slices.Equal(want, got) {
+		t.Errorf("expected to resubscribe to %v, got %v", want, got)
+	}
+
+	failed := make([]Channel, 0)
+	for err := range errs {
+		var subErr SubscriptionFailedError
+		if !errors.As(err, &subErr) {
+			t.Fatalf("expected a SubscriptionFailedError, got %v", err)
+		}
+		failed = append(failed, subErr.Channels...)
+	}
+	if want := []Channel{"/foo/baz"}; !slices.Equal(want, failed) {
+		t.Errorf("expected failures for %v, got %v", want, failed)
+	}
+}
+
+func TestClient_RehandshakeDoesNotWaitForErrors(t *testing.T) {
+	c, err := NewClient("https://example.com", WithBayeuxTransport(newResubscribeTransport()))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if _, err := c.subscriptions.Add("/foo/baz", newSubscriber(make(chan []Message))); err != nil {
+		t.Fatalf("failed to add subscription (%v)", err)
+	}
+
+	// Nobody receives the failure to resubscribe
+	done := make(chan error, 1)
+	go func() {
+		done <- c.rehandshake(context.Background(), make(chan error))
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected rehandshake to succeed but got %q", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("rehandshake waited for its error to be received")
+	}
+}
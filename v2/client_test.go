@@ -2,7 +2,13 @@ package gobayeux_test
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"strings"
 	"sync"
 	"testing"
@@ -270,6 +276,90 @@ func TestUnsubscribeWithContext(t *testing.T) {
 	})
 }
 
+func TestDisconnectTwice(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	_ = client.Start(context.Background())
+	for server.Connects() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- client.Disconnect(context.Background())
+		}()
+	}
+	var notConnected int
+	for i := 0; i < 2; i++ {
+		err := <-errs
+		switch {
+		case errors.Is(err, gobayeux.ErrClientNotConnected):
+			notConnected++
+		case err != nil:
+			t.Errorf("failed to disconnect (%v)", err)
+		}
+	}
+	if notConnected != 1 {
+		t.Errorf("expected one of two calls to Disconnect to fail with ErrClientNotConnected but %d did", notConnected)
+	}
+
+	if err := client.Disconnect(context.Background()); !errors.Is(err, gobayeux.ErrClientNotConnected) {
+		t.Errorf("expected ErrClientNotConnected but got %v", err)
+	}
+}
+
+func TestSubscribeAfterDisconnect(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	_ = client.Start(context.Background())
+	for server.Connects() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+
+	// More requests than the request channels buffer must not block
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		msgs := make(chan []gobayeux.Message)
+		for i := 0; i < 20; i++ {
+			client.Subscribe("/foo/bar", msgs)
+			client.Unsubscribe("/foo/bar")
+			if err := client.SubscribeWithContext(context.Background(), "/foo/bar", msgs); err != nil && !errors.Is(err, gobayeux.ErrClientNotConnected) {
+				t.Errorf("expected ErrClientNotConnected but got %v", err)
+			}
+			if err := client.UnsubscribeWithContext(context.Background(), "/foo/bar"); err != nil && !errors.Is(err, gobayeux.ErrClientNotConnected) {
+				t.Errorf("expected ErrClientNotConnected but got %v", err)
+			}
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("requests blocked after Disconnect")
+	}
+}
+
 func TestErrorParsing(t *testing.T) {
 	server := gobayeuxtest.NewServer(t, gobayeuxtest.WithHandshakeError(true))
 	if err := server.Start(context.Background()); err != nil {
@@ -301,3 +391,727 @@ func TestErrorParsing(t *testing.T) {
 		t.Fatalf("failed to stop test server (%v)", err)
 	}
 }
+
+func TestPublish(t *testing.T) {
+	testCases := []struct {
+		name         string
+		publishError bool
+		shouldErr    bool
+	}{
+		{"server accepts the message", false, false},
+		{"server rejects the message", true, true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			server := gobayeuxtest.NewServer(t, gobayeuxtest.WithPublishError(tc.publishError))
+			if err := server.Start(context.Background()); err != nil {
+				t.Fatalf("failed to start test server (%v)", err)
+			}
+
+			client, err := gobayeux.NewClient(
+				"https://example.com",
+				gobayeux.WithHTTPTransport(server),
+			)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			msgs := make(chan []gobayeux.Message, 10)
+			_ = client.Start(ctx)
+			client.Subscribe("/foo/bar", msgs)
+
+			// Receiving a message means we have completed the handshake
+			select {
+			case <-msgs:
+			case <-ctx.Done():
+				t.Fatal("timeout waiting for the client to connect")
+			}
+
+			err = client.Publish(ctx, []gobayeux.Message{
+				{Channel: "/foo/bar", Data: json.RawMessage(`{"hello":"world"}`)},
+			})
+			if tc.shouldErr {
+				var publishErr gobayeux.PublishFailedError
+				if !errors.As(err, &publishErr) {
+					t.Fatalf("expected a PublishFailedError but got %v", err)
+				}
+			} else if err != nil {
+				t.Fatalf("expected publish to succeed but got %v", err)
+			}
+
+			if err := server.Stop(context.Background()); err != nil {
+				t.Fatalf("failed to stop test server (%v)", err)
+			}
+		})
+	}
+}
+
+func TestPublishBeforeConnecting(t *testing.T) {
+	client, err := gobayeux.NewClient("https://example.com")
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	err = client.Publish(context.Background(), []gobayeux.Message{
+		{Channel: "/foo/bar", Data: json.RawMessage(`{}`)},
+	})
+	if !errors.Is(err, gobayeux.ErrClientNotConnected) {
+		t.Fatalf("expected ErrClientNotConnected but got %v", err)
+	}
+}
+
+func TestWebSocket(t *testing.T) {
+	testCases := []struct {
+		name            string
+		connectionTypes []string
+		wantWebSocket   bool
+	}{
+		{"server supports websocket", []string{"websocket", "long-polling"}, true},
+		{"server falls back to long-polling", []string{"long-polling"}, false},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			server := gobayeuxtest.NewServer(t, gobayeuxtest.WithSupportedConnectionTypes(tc.connectionTypes...))
+			if err := server.Start(context.Background()); err != nil {
+				t.Fatalf("failed to start test server (%v)", err)
+			}
+			httpServer := httptest.NewServer(server)
+			defer httpServer.Close()
+
+			client, err := gobayeux.NewClient(httpServer.URL, gobayeux.WithWebSocket())
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			msgs := make(chan []gobayeux.Message, 10)
+			errs := client.Start(ctx)
+			client.Subscribe("/foo/bar", msgs)
+
+			select {
+			case <-msgs:
+			case err := <-errs:
+				t.Fatalf("unexpected error from client (%v)", err)
+			case <-ctx.Done():
+				t.Fatal("timeout waiting for messages")
+			}
+
+			err = client.Publish(ctx, []gobayeux.Message{
+				{Channel: "/foo/bar", Data: json.RawMessage(`{"hello":"world"}`)},
+			})
+			if err != nil {
+				t.Fatalf("expected publish to succeed but got %v", err)
+			}
+
+			if got := server.WebSocketMessages() > 0; got != tc.wantWebSocket {
+				t.Errorf("expected websocket use to be %t but it was %t", tc.wantWebSocket, got)
+			}
+
+			if err := client.Disconnect(context.Background()); err != nil {
+				t.Fatalf("failed to disconnect (%v)", err)
+			}
+
+			if err := server.Stop(context.Background()); err != nil {
+				t.Fatalf("failed to stop test server (%v)", err)
+			}
+		})
+	}
+}
+
+func TestAdviceNoneStopsClient(t *testing.T) {
+	server := gobayeuxtest.NewServer(t, gobayeuxtest.WithConnectAdvice(&gobayeux.Advice{Reconnect: "none"}))
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	select {
+	case err := <-client.Start(ctx):
+		if !errors.Is(err, gobayeux.ErrMustNotReconnect) {
+			t.Fatalf("expected ErrMustNotReconnect but got %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for the client to stop")
+	}
+}
+
+func TestHandshakeFollowsAdvice(t *testing.T) {
+	testCases := []struct {
+		name       string
+		reconnect  string
+		handshakes int
+		shouldErr  bool
+	}{
+		{"retry", "retry", 3, false},
+		{"handshake", "handshake", 3, false},
+		{"none", "none", 1, true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			server := gobayeuxtest.NewServer(t, gobayeuxtest.WithHandshakeAdvice(2, &gobayeux.Advice{Reconnect: tc.reconnect, Interval: 10}))
+			if err := server.Start(context.Background()); err != nil {
+				t.Fatalf("failed to start test server (%v)", err)
+			}
+
+			client, err := gobayeux.NewClient(
+				"https://example.com",
+				gobayeux.WithHTTPTransport(server),
+				gobayeux.WithBackoff(gobayeux.ConstantBackoff{Interval: time.Millisecond}),
+			)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			errs := client.Start(ctx)
+			for server.Connects() == 0 && !tc.shouldErr {
+				select {
+				case err := <-errs:
+					t.Fatalf("unexpected error from client (%v)", err)
+				case <-ctx.Done():
+					t.Fatalf("timeout after %d handshakes", server.Handshakes())
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+			if tc.shouldErr {
+				select {
+				case err := <-errs:
+					var handshakeErr *gobayeux.HandshakeFailedError
+					if !errors.As(err, &handshakeErr) {
+						t.Errorf("expected a HandshakeFailedError but got %v", err)
+					}
+					var replyErr gobayeux.UnsuccessfulReplyError
+					if !errors.As(err, &replyErr) || replyErr.Reply.Error != "403::Handshake denied" {
+						t.Errorf("expected the rejected handshake reply but got %v", err)
+					}
+				case <-ctx.Done():
+					t.Fatal("timeout waiting for the client to stop")
+				}
+			}
+
+			if got := server.Handshakes(); got != tc.handshakes {
+				t.Errorf("expected %d handshakes but got %d", tc.handshakes, got)
+			}
+		})
+	}
+}
+
+func TestAdvisedRetriesBackOff(t *testing.T) {
+	advice := &gobayeux.Advice{Reconnect: gobayeux.ReconnectRetry}
+	testCases := []struct {
+		name     string
+		opt      gobayeuxtest.ServerOpts
+		attempts func(*gobayeuxtest.Server) int
+	}{
+		{"handshake", gobayeuxtest.WithHandshakeAdvice(math.MaxInt, advice), (*gobayeuxtest.Server).Handshakes},
+		{"connect", gobayeuxtest.WithRefusedConnects(math.MaxInt, advice), (*gobayeuxtest.Server).Connects},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			server := gobayeuxtest.NewServer(t, tc.opt)
+			if err := server.Start(context.Background()); err != nil {
+				t.Fatalf("failed to start test server (%v)", err)
+			}
+
+			client, err := gobayeux.NewClient(
+				"https://example.com",
+				gobayeux.WithHTTPTransport(server),
+				gobayeux.WithBackoff(gobayeux.ConstantBackoff{Interval: 20 * time.Millisecond, MaxElapsed: 100 * time.Millisecond}),
+			)
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			// The server advises retrying without an interval, which
+			// must not be done in a tight loop or forever
+			select {
+			case err := <-client.Start(ctx):
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+			case <-ctx.Done():
+				t.Fatal("timeout waiting for the client to give up")
+			}
+			if got := tc.attempts(server); got < 2 || got > 10 {
+				t.Errorf("expected a few attempts spaced out by the backoff but got %d", got)
+			}
+		})
+	}
+}
+
+func TestAdviceHandshakeResubscribes(t *testing.T) {
+	server := gobayeuxtest.NewServer(t, gobayeuxtest.WithUnknownClientEvery(3))
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msgs := make(chan []gobayeux.Message, 10)
+	errs := client.Start(ctx)
+	client.Subscribe("/foo/bar", msgs)
+
+	// Keep receiving until the server has forgotten our session at least
+	// twice; messages only continue to arrive if we resubscribed
+	for server.Handshakes() < 3 {
+		select {
+		case <-msgs:
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatalf("timeout after %d handshakes", server.Handshakes())
+		}
+	}
+
+	select {
+	case <-msgs:
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for messages after re-handshaking")
+	}
+
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+}
+
+func TestBackoffRetriesTransientConnectFailures(t *testing.T) {
+	server := gobayeuxtest.NewServer(t, gobayeuxtest.WithUnavailableConnects(3))
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithBackoff(gobayeux.ConstantBackoff{Interval: time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msgs := make(chan []gobayeux.Message, 10)
+	errs := client.Start(ctx)
+	client.Subscribe("/foo/bar", msgs)
+
+	select {
+	case <-msgs:
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for messages")
+	}
+
+	if connects := server.Connects(); connects <= 3 {
+		t.Errorf("expected more than 3 /meta/connect requests but got %d", connects)
+	}
+}
+
+func TestBackoffGivesUpAfterMaxElapsedTime(t *testing.T) {
+	server := gobayeuxtest.NewServer(t, gobayeuxtest.WithUnavailableConnects(1000))
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient(
+		"https://example.com",
+		gobayeux.WithHTTPTransport(server),
+		gobayeux.WithBackoff(gobayeux.ExponentialBackoff{
+			Initial:    time.Millisecond,
+			Max:        10 * time.Millisecond,
+			MaxElapsed: 50 * time.Millisecond,
+		}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	select {
+	case err := <-client.Start(ctx):
+		var badResponse gobayeux.BadResponseError
+		if !errors.As(err, &badResponse) || badResponse.StatusCode != http.StatusServiceUnavailable {
+			t.Fatalf("expected a 503 BadResponseError but got %v", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for the client to give up")
+	}
+}
+
+func TestFailoverToAlternateServer(t *testing.T) {
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	client, err := gobayeux.NewClient(unreachable.URL, gobayeux.WithAlternateServers(httpServer.URL))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msgs := make(chan []gobayeux.Message, 10)
+	errs := client.Start(ctx)
+	client.Subscribe("/foo/bar", msgs)
+
+	select {
+	case <-msgs:
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for messages")
+	}
+}
+
+type countingExtension struct {
+	mu       sync.Mutex
+	incoming int
+}
+
+func (e *countingExtension) Outgoing(*gobayeux.Message) {}
+
+func (e *countingExtension) Incoming(*gobayeux.Message) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.incoming++
+}
+
+func (e *countingExtension) Registered(string, *gobayeux.BayeuxClient) {}
+
+func (e *countingExtension) Unregistered() {}
+
+func (e *countingExtension) Count() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.incoming
+}
+
+func TestFailoverMidSessionBeforeBackingOff(t *testing.T) {
+	first := gobayeuxtest.NewServer(t)
+	second := gobayeuxtest.NewServer(t)
+	for _, server := range []*gobayeuxtest.Server{first, second} {
+		if err := server.Start(context.Background()); err != nil {
+			t.Fatalf("failed to start test server (%v)", err)
+		}
+	}
+	firstHTTP := httptest.NewServer(first)
+	defer firstHTTP.Close()
+	secondHTTP := httptest.NewServer(second)
+	defer secondHTTP.Close()
+
+	// Without a MaxElapsedTime the first server would be retried forever
+	client, err := gobayeux.NewClient(
+		firstHTTP.URL,
+		gobayeux.WithAlternateServers(secondHTTP.URL),
+		gobayeux.WithBackoff(gobayeux.ConstantBackoff{Interval: 10 * time.Millisecond}),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	errs := client.Start(ctx)
+	for first.Connects() == 0 {
+		time.Sleep(10 * time.Millisecond)
+	}
+	firstHTTP.Close()
+
+	for second.Connects() == 0 {
+		select {
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for the client to fail over")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+func TestFollowHostsAdvice(t *testing.T) {
+	alternate := gobayeuxtest.NewServer(t)
+	if err := alternate.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	alternateServer := httptest.NewServer(alternate)
+	defer alternateServer.Close()
+	alternateURL, err := url.Parse(alternateServer.URL)
+	if err != nil {
+		t.Fatalf("failed to parse test server URL (%v)", err)
+	}
+
+	server := gobayeuxtest.NewServer(t, gobayeuxtest.WithConnectAdvice(&gobayeux.Advice{
+		Reconnect: "handshake",
+		Hosts:     []string{alternateURL.Host},
+	}))
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	httpServer := httptest.NewServer(server)
+	defer httpServer.Close()
+
+	client, err := gobayeux.NewClient(httpServer.URL)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	ext := &countingExtension{}
+	if err := client.UseExtension(ext); err != nil {
+		t.Fatalf("failed to register extension (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msgs := make(chan []gobayeux.Message, 10)
+	errs := client.Start(ctx)
+	client.Subscribe("/foo/bar", msgs)
+
+	// Messages keep arriving after moving to the advised host only if our
+	// subscription was carried over
+	for alternate.Connects() < 3 {
+		select {
+		case <-msgs:
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatalf("timeout after %d /meta/connect requests to the advised host", alternate.Connects())
+		}
+	}
+
+	if got := alternate.Handshakes(); got != 1 {
+		t.Errorf("expected 1 handshake with the advised host but got %d", got)
+	}
+	if got := server.Handshakes(); got != 1 {
+		t.Errorf("expected 1 handshake with the original server but got %d", got)
+	}
+	if ext.Count() <= alternate.Connects() {
+		t.Errorf("expected the extension to see replies from both servers but it saw %d", ext.Count())
+	}
+}
+
+func TestOn(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+
+	first := make(chan gobayeux.Message, 10)
+	second := make(chan gobayeux.Message, 10)
+	listeners := make([]*gobayeux.Subscription, 0, 2)
+	for _, received := range []chan gobayeux.Message{first, second} {
+		sub, err := client.On("/foo/bar", func(_ context.Context, m gobayeux.Message) {
+			select {
+			case received <- m:
+			default:
+			}
+		})
+		if err != nil {
+			t.Fatalf("failed to register listener (%v)", err)
+		}
+		listeners = append(listeners, sub)
+	}
+
+	for _, received := range []chan gobayeux.Message{first, second} {
+		select {
+		case m := <-received:
+			if m.Channel != "/foo/bar" {
+				t.Errorf("expected a message on /foo/bar but got %s", m.Channel)
+			}
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for messages")
+		}
+	}
+	if got := server.Requests(gobayeux.MetaSubscribe); got != 1 {
+		t.Errorf("expected 1 /meta/subscribe request but got %d", got)
+	}
+
+	// The remaining listener keeps the subscription alive
+	listeners[0].Unsubscribe()
+	drain(first)
+	drain(second)
+	select {
+	case <-second:
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for messages after first listener left")
+	}
+	if got := server.Requests(gobayeux.MetaUnsubscribe); got != 0 {
+		t.Errorf("expected no /meta/unsubscribe request but got %d", got)
+	}
+
+	listeners[1].Unsubscribe()
+	for server.Requests(gobayeux.MetaUnsubscribe) == 0 {
+		select {
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for /meta/unsubscribe")
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+}
+
+func drain(ms chan gobayeux.Message) {
+	for {
+		select {
+		case <-ms:
+		default:
+			return
+		}
+	}
+}
+
+func TestSubscribeAndWait(t *testing.T) {
+	server := gobayeuxtest.NewServer(t, gobayeuxtest.WithDeniedChannels("/denied"))
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = client.Start(ctx)
+
+	msgs := make(chan []gobayeux.Message)
+	go func() {
+		for {
+			select {
+			case <-msgs:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	t.Run("accepted", func(t *testing.T) {
+		result, err := client.SubscribeAndWait(ctx, "/foo/bar", msgs)
+		if err != nil {
+			t.Fatalf("expected subscription to succeed but got %v", err)
+		}
+		if !result.Successful || result.Channel != "/foo/bar" || result.Error != nil {
+			t.Errorf("unexpected result %+v", result)
+		}
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		result, err := client.SubscribeAndWait(ctx, "/denied", msgs)
+		var subErr gobayeux.SubscriptionFailedError
+		if !errors.As(err, &subErr) {
+			t.Fatalf("expected a SubscriptionFailedError but got %v", err)
+		}
+		var replyErr gobayeux.UnsuccessfulReplyError
+		if !errors.As(err, &replyErr) || replyErr.Reply.Subscription != "/denied" {
+			t.Errorf("expected the rejected subscribe reply but got %v", err)
+		}
+		if result.Successful || result.Error == nil {
+			t.Fatalf("expected an unsuccessful result with an error but got %+v", result)
+		}
+		if result.Error.ErrorCode != 403 || result.Error.ErrorMessage != "Subscription denied" {
+			t.Errorf("unexpected error %+v", result.Error)
+		}
+	})
+
+	t.Run("unsubscribe", func(t *testing.T) {
+		result, err := client.UnsubscribeAndWait(ctx, "/foo/bar")
+		if err != nil {
+			t.Fatalf("expected unsubscribe to succeed but got %v", err)
+		}
+		if !result.Successful {
+			t.Errorf("unexpected result %+v", result)
+		}
+
+		result, err = client.UnsubscribeAndWait(ctx, "/foo/bar")
+		var unsubErr gobayeux.UnsubscribeFailedError
+		if !errors.As(err, &unsubErr) {
+			t.Fatalf("expected an UnsubscribeFailedError but got %v", err)
+		}
+		if result.Successful {
+			t.Errorf("unexpected result %+v", result)
+		}
+	})
+
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+}
+
+func TestSubscribeAndWaitRespectsContext(t *testing.T) {
+	client, err := gobayeux.NewClient("https://example.com")
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	// Without Start nothing answers the request
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := client.SubscribeAndWait(ctx, "/foo/bar", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded but got %v", err)
+	}
+	if _, err := client.UnsubscribeAndWait(ctx, "/foo/bar"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded but got %v", err)
+	}
+}
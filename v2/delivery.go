@@ -0,0 +1,229 @@
+package gobayeux
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// DeliveryStrategy determines what happens to messages for a subscriber that
+// is not keeping up with them
+type DeliveryStrategy int
+
+const (
+	// DeliveryBlock waits for the subscriber to receive each batch. Every
+	// other subscription waits along with it, so a subscriber that stops
+	// receiving stalls the client until its session times out.
+	DeliveryBlock DeliveryStrategy = iota
+	// DeliveryDropOldest discards the oldest waiting messages to make room
+	// for new ones
+	DeliveryDropOldest
+	// DeliveryDropNewest discards new messages while the subscriber has
+	// Limit messages waiting
+	DeliveryDropNewest
+	// DeliverySpill passes new messages to OnSpill while the subscriber
+	// has Limit messages waiting
+	DeliverySpill
+)
+
+// DefaultDeliveryLimit is how many messages may wait for a subscriber when a
+// DeliveryPolicy does not say
+const DefaultDeliveryLimit = 100
+
+// DeliveryPolicy determines how messages are delivered to a subscriber. The
+// zero value blocks.
+//
+// With any strategy other than DeliveryBlock, batches the subscriber is not
+// ready to receive wait in a queue of their own so that other subscriptions
+// are not held up. Each channel's messages are still received in order.
+type DeliveryPolicy struct {
+	Strategy DeliveryStrategy
+	// Limit is how many messages may wait for the subscriber, besides the
+	// batch being handed to it, before Strategy applies. It defaults to
+	// DefaultDeliveryLimit.
+	Limit int
+	// OnSpill is called with the messages that do not fit when Strategy is
+	// DeliverySpill. It is called from the polling task so it must not
+	// block.
+	OnSpill func([]Message)
+}
+
+func (p DeliveryPolicy) limit() int {
+	if p.Limit <= 0 {
+		return DefaultDeliveryLimit
+	}
+	return p.Limit
+}
+
+// DeliveryStats counts the messages handled for a single subscriber
+type DeliveryStats struct {
+	// Delivered is the number of messages the subscriber has received
+	Delivered uint64
+	// Dropped is the number of messages discarded by DeliveryDropOldest
+	// or DeliveryDropNewest
+	Dropped uint64
+	// Spilled is the number of messages passed to OnSpill
+	Spilled uint64
+}
+
+// SubscribeOptions stores the available configuration options for a single
+// subscription
+type SubscribeOptions struct {
+	DeliveryPolicy DeliveryPolicy
+}
+
+// SubscribeOption defines the type passed into Subscribe and On for
+// configuration
+type SubscribeOption func(*SubscribeOptions)
+
+// WithDeliveryPolicy returns a SubscribeOption with policy
+func WithDeliveryPolicy(policy DeliveryPolicy) SubscribeOption {
+	return func(options *SubscribeOptions) {
+		options.DeliveryPolicy = policy
+	}
+}
+
+func newSubscribeOptions(opts []SubscribeOption) SubscribeOptions {
+	options := SubscribeOptions{}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&options)
+		}
+	}
+	return options
+}
+
+// newChannelSubscriber creates a subscriber for msgs configured with opts
+func newChannelSubscriber(msgs chan []Message, opts []SubscribeOption) *subscriber {
+	return newSubscriber(msgs).withPolicy(newSubscribeOptions(opts).DeliveryPolicy)
+}
+
+// deliveryQueue holds the batches waiting for a subscriber which does not
+// block
+type deliveryQueue struct {
+	mu      sync.Mutex
+	batches [][]Message
+	waiting int
+	ready   chan struct{}
+	start   sync.Once
+}
+
+type deliveryCounters struct {
+	delivered atomic.Uint64
+	dropped   atomic.Uint64
+	spilled   atomic.Uint64
+}
+
+// stats returns a snapshot of the subscriber's counters
+func (s *subscriber) stats() DeliveryStats {
+	return DeliveryStats{
+		Delivered: s.counters.delivered.Load(),
+		Dropped:   s.counters.dropped.Load(),
+		Spilled:   s.counters.spilled.Load(),
+	}
+}
+
+// deliver hands batch to the subscriber according to its policy. It reports
+// false if it gave up because stopped was closed.
+func (s *subscriber) deliver(batch []Message, stopped <-chan struct{}) bool {
+	if s.policy.Strategy == DeliveryBlock {
+		select {
+		case s.msgs <- batch:
+			s.counters.delivered.Add(uint64(len(batch)))
+		case <-s.done:
+		case <-stopped:
+			return false
+		}
+		return true
+	}
+
+	s.queue.start.Do(func() {
+		go s.forward(stopped)
+	})
+	s.enqueue(batch)
+	return true
+}
+
+// enqueue adds batch to the queue, applying the policy to whatever does not
+// fit
+func (s *subscriber) enqueue(batch []Message) {
+	q := s.queue
+	q.mu.Lock()
+	limit := s.policy.limit()
+	var spill []Message
+	switch s.policy.Strategy {
+	case DeliveryDropOldest:
+		if len(batch) > limit {
+			s.counters.dropped.Add(uint64(len(batch) - limit))
+			batch = batch[len(batch)-limit:]
+		}
+		for q.waiting+len(batch) > limit {
+			excess := min(q.waiting+len(batch)-limit, len(q.batches[0]))
+			q.batches[0] = q.batches[0][excess:]
+			q.waiting -= excess
+			s.counters.dropped.Add(uint64(excess))
+			if len(q.batches[0]) == 0 {
+				q.batches = q.batches[1:]
+			}
+		}
+	case DeliveryDropNewest, DeliverySpill:
+		if room := max(limit-q.waiting, 0); len(batch) > room {
+			if s.policy.Strategy == DeliverySpill {
+				spill = batch[room:]
+			} else {
+				s.counters.dropped.Add(uint64(len(batch) - room))
+			}
+			batch = batch[:room]
+		}
+	}
+	if len(batch) > 0 {
+		q.batches = append(q.batches, batch)
+		q.waiting += len(batch)
+	}
+	q.mu.Unlock()
+
+	if len(batch) > 0 {
+		select {
+		case q.ready <- struct{}{}:
+		default:
+		}
+	}
+	if len(spill) > 0 {
+		s.counters.spilled.Add(uint64(len(spill)))
+		if s.policy.OnSpill != nil {
+			s.policy.OnSpill(spill)
+		}
+	}
+}
+
+// forward sends each queued batch to the subscriber in turn until it stops
+// receiving or stopped is closed
+func (s *subscriber) forward(stopped <-chan struct{}) {
+	q := s.queue
+	for {
+		q.mu.Lock()
+		if len(q.batches) == 0 {
+			q.mu.Unlock()
+			select {
+			case <-q.ready:
+				continue
+			case <-s.done:
+				return
+			case <-stopped:
+				return
+			}
+		}
+		batch := q.batches[0]
+		q.batches = q.batches[1:]
+		q.waiting -= len(batch)
+		q.mu.Unlock()
+
+		select {
+		case s.msgs <- batch:
+			s.counters.delivered.Add(uint64(len(batch)))
+		case <-s.done:
+			return
+		case <-stopped:
+			return
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package gobayeux
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// messages creates a batch of messages on /foo with the given IDs
+func messages(ids ...string) []Message {
+	batch := make([]Message, 0, len(ids))
+	for _, id := range ids {
+		batch = append(batch, Message{Channel: "/foo", ID: id})
+	}
+	return batch
+}
+
+func TestSubscriber_Enqueue(t *testing.T) {
+	testCases := []struct {
+		name     string
+		strategy DeliveryStrategy
+		batches  [][]Message
+		expected [][]Message
+		spilled  [][]Message
+		stats    DeliveryStats
+	}{
+		{
+			name:     "drop oldest",
+			strategy: DeliveryDropOldest,
+			batches:  [][]Message{messages("1", "2"), messages("3", "4"), messages("5", "6", "7", "8")},
+			expected: [][]Message{messages("6", "7", "8")},
+			stats:    DeliveryStats{Dropped: 5},
+		},
+		{
+			name:     "drop oldest within limit",
+			strategy: DeliveryDropOldest,
+			batches:  [][]Message{messages("1"), messages("2", "3")},
+			expected: [][]Message{messages("1"), messages("2", "3")},
+		},
+		{
+			name:     "drop newest",
+			strategy: DeliveryDropNewest,
+			batches:  [][]Message{messages("1", "2"), messages("3", "4"), messages("5")},
+			expected: [][]Message{messages("1", "2"), messages("3")},
+			stats:    DeliveryStats{Dropped: 2},
+		},
+		{
+			name:     "spill",
+			strategy: DeliverySpill,
+			batches:  [][]Message{messages("1", "2"), messages("3", "4"), messages("5")},
+			expected: [][]Message{messages("1", "2"), messages("3")},
+			spilled:  [][]Message{messages("4"), messages("5")},
+			stats:    DeliveryStats{Spilled: 2},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			spilled := make([][]Message, 0)
+			s := newSubscriber(make(chan []Message)).withPolicy(DeliveryPolicy{
+				Strategy: tc.strategy,
+				Limit:    3,
+				OnSpill: func(ms []Message) {
+					spilled = append(spilled, ms)
+				},
+			})
+			for _, batch := range tc.batches {
+				s.enqueue(batch)
+			}
+
+			if !reflect.DeepEqual(s.queue.batches, tc.expected) {
+				t.Errorf("expected %v to be queued but got %v", tc.expected, s.queue.batches)
+			}
+			if tc.spilled == nil {
+				tc.spilled = [][]Message{}
+			}
+			if !reflect.DeepEqual(spilled, tc.spilled) {
+				t.Errorf("expected %v to be spilled but got %v", tc.spilled, spilled)
+			}
+			if s.stats() != tc.stats {
+				t.Errorf("expected stats %+v but got %+v", tc.stats, s.stats())
+			}
+		})
+	}
+}
+
+func TestClient_SlowSubscriberDoesNotBlockOthers(t *testing.T) {
+	c, err := NewClient("https://example.com")
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	// Nobody receives from slow until every batch has been dispatched
+	slow := make(chan []Message)
+	fast := make(chan []Message, 10)
+	if _, err := c.subscriptions.Add("/foo", newChannelSubscriber(slow, []SubscribeOption{
+		WithDeliveryPolicy(DeliveryPolicy{Strategy: DeliveryDropNewest, Limit: 2}),
+	})); err != nil {
+		t.Fatalf("failed to add subscription (%v)", err)
+	}
+	if _, err := c.subscriptions.Add("/foo", newSubscriber(fast)); err != nil {
+		t.Fatalf("failed to add subscription (%v)", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			c.dispatcher.Dispatch(messages("1"))
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the slow subscriber blocked delivery")
+	}
+	if len(fast) != 10 {
+		t.Errorf("expected 10 batches to be delivered but got %d", len(fast))
+	}
+
+	// At most the batch being handed over plus Limit are kept
+	received := 0
+	for received < 2 {
+		select {
+		case <-slow:
+			received++
+		case <-time.After(time.Second):
+			t.Fatalf("expected queued batches to be delivered but got %d", received)
+		}
+	}
+	stats, ok := c.DeliveryStats("/foo", slow)
+	if !ok {
+		t.Fatal("expected stats for the slow subscriber")
+	}
+	if stats.Dropped < 7 || stats.Dropped+stats.Delivered+uint64(len(slow)) > 10 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	if _, ok := c.DeliveryStats("/bar", slow); ok {
+		t.Error("expected no stats for a channel without the subscriber")
+	}
+
+	c.subscriptions.Remove("/foo")
+}
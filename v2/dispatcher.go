@@ -0,0 +1,117 @@
+package gobayeux
+
+import (
+	"slices"
+	"sync"
+)
+
+// metaHandler is called with the replies received on the meta channel it is
+// registered for
+type metaHandler func([]Message)
+
+// dispatcher delivers the messages received in reply to /meta/connect.
+//
+// Consecutive messages on the same channel are delivered together as one
+// batch to every subscriber to a matching channel. Batches are delivered one
+// at a time in the order they were received so that each subscriber sees the
+// messages for each channel in the order the server sent them. Replies on
+// meta channels are never delivered to subscribers; they are passed to the
+// handler registered for their channel instead. Other messages may be
+// claimed before they are delivered, e.g. replies to Client.Call.
+type dispatcher struct {
+	subscriptions *subscriptionsMap
+	logger        Logger
+	// stopped is closed once the client shuts down so that deliveries do
+	// not block forever on subscribers that have stopped reading
+	stopped <-chan struct{}
+
+	mu       sync.RWMutex
+	handlers map[Channel]metaHandler
+	claim    func(Message) bool
+}
+
+func newDispatcher(subscriptions *subscriptionsMap, logger Logger, stopped <-chan struct{}) *dispatcher {
+	return &dispatcher{
+		subscriptions: subscriptions,
+		logger:        logger,
+		stopped:       stopped,
+		handlers:      make(map[Channel]metaHandler),
+	}
+}
+
+// Handle registers handler for replies on the meta channel, replacing any
+// previous handler
+func (d *dispatcher) Handle(channel Channel, handler metaHandler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.handlers[channel] = handler
+}
+
+// Claim registers claim to be offered every message on a channel other than
+// a meta channel. Messages it returns true for are not delivered.
+func (d *dispatcher) Claim(claim func(Message) bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.claim = claim
+}
+
+// Dispatch delivers every message in ms
+func (d *dispatcher) Dispatch(ms []Message) {
+	d.mu.RLock()
+	claim := d.claim
+	d.mu.RUnlock()
+	if claim != nil {
+		ms = slices.DeleteFunc(slices.Clone(ms), func(m Message) bool {
+			return m.Channel.Type() != MetaChannel && claim(m)
+		})
+	}
+
+	start := 0
+	for i := 1; i <= len(ms); i++ {
+		if i < len(ms) && ms[i].Channel == ms[start].Channel {
+			continue
+		}
+		d.dispatch(ms[start].Channel, ms[start:i:i])
+		start = i
+	}
+}
+
+func (d *dispatcher) dispatch(channel Channel, batch []Message) {
+	if channel.Type() != MetaChannel {
+		d.deliver(channel, batch)
+		return
+	}
+
+	d.mu.RLock()
+	handler, ok := d.handlers[channel]
+	d.mu.RUnlock()
+	if !ok {
+		d.logger.WithField("at", "dispatch").WithField("channel", channel).Debug("ignoring replies without a handler")
+		return
+	}
+	handler(batch)
+}
+
+// deliver sends batch to every subscriber to a channel matching channel.
+// Messages on a channel nobody is subscribed to, e.g., those arriving just
+// after unsubscribing, are dropped.
+func (d *dispatcher) deliver(channel Channel, batch []Message) {
+	logger := d.logger.WithField("at", "deliver").WithField("channel", channel)
+	subscribers := d.subscriptions.Match(channel)
+	if len(subscribers) == 0 {
+		logger.WithField("messages", len(batch)).Warn("dropping messages for channel without subscriptions")
+		return
+	}
+
+	for i, s := range subscribers {
+		if i > 0 {
+			// Every subscriber gets its own slice to do with as it
+			// pleases
+			batch = slices.Clone(batch)
+		}
+		logger.Debug("sending batch")
+		if !s.deliver(batch, d.stopped) {
+			return
+		}
+	}
+}
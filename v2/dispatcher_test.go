@@ -0,0 +1,179 @@
+package gobayeux
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// received drains every batch waiting in msgs
+func received(msgs chan []Message) [][]Message {
+	batches := make([][]Message, 0)
+	for {
+		select {
+		case batch := <-msgs:
+			batches = append(batches, batch)
+		default:
+			return batches
+		}
+	}
+}
+
+func TestDispatcher_Dispatch(t *testing.T) {
+	testCases := []struct {
+		name     string
+		messages []Message
+		expected map[Channel][][]Message
+		meta     [][]Message
+	}{
+		{
+			name:     "single message",
+			messages: []Message{{Channel: "/foo/bar", ID: "1"}},
+			expected: map[Channel][][]Message{
+				"/foo/bar": {{{Channel: "/foo/bar", ID: "1"}}},
+				"/foo/*":   {{{Channel: "/foo/bar", ID: "1"}}},
+			},
+		},
+		{
+			name: "final batch",
+			messages: []Message{
+				{Channel: "/foo/bar", ID: "1"},
+				{Channel: "/foo/bar", ID: "2"},
+				{Channel: "/foo/baz", ID: "3"},
+				{Channel: "/foo/baz", ID: "4"},
+			},
+			expected: map[Channel][][]Message{
+				"/foo/bar": {{{Channel: "/foo/bar", ID: "1"}, {Channel: "/foo/bar", ID: "2"}}},
+				"/foo/baz": {{{Channel: "/foo/baz", ID: "3"}, {Channel: "/foo/baz", ID: "4"}}},
+				"/foo/*": {
+					{{Channel: "/foo/bar", ID: "1"}, {Channel: "/foo/bar", ID: "2"}},
+					{{Channel: "/foo/baz", ID: "3"}, {Channel: "/foo/baz", ID: "4"}},
+				},
+			},
+		},
+		{
+			name: "interleaved channels with connect reply",
+			messages: []Message{
+				{Channel: "/foo/bar", ID: "1"},
+				{Channel: "/foo/baz", ID: "2"},
+				{Channel: "/foo/bar", ID: "3"},
+				{Channel: MetaConnect, ID: "4", Successful: true},
+			},
+			expected: map[Channel][][]Message{
+				"/foo/bar": {{{Channel: "/foo/bar", ID: "1"}}, {{Channel: "/foo/bar", ID: "3"}}},
+				"/foo/baz": {{{Channel: "/foo/baz", ID: "2"}}},
+				"/foo/*": {
+					{{Channel: "/foo/bar", ID: "1"}},
+					{{Channel: "/foo/baz", ID: "2"}},
+					{{Channel: "/foo/bar", ID: "3"}},
+				},
+			},
+			meta: [][]Message{{{Channel: MetaConnect, ID: "4", Successful: true}}},
+		},
+		{
+			name: "unhandled meta replies and unsubscribed channels",
+			messages: []Message{
+				{Channel: MetaSubscribe, ID: "1"},
+				{Channel: "/qux", ID: "2"},
+			},
+			expected: map[Channel][][]Message{},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			subscriptions := newSubscriptionsMap()
+			msgChans := map[Channel]chan []Message{
+				"/foo/bar": make(chan []Message, 5),
+				"/foo/baz": make(chan []Message, 5),
+				"/foo/*":   make(chan []Message, 5),
+			}
+			for channel, msgs := range msgChans {
+				if _, err := subscriptions.Add(channel, newSubscriber(msgs)); err != nil {
+					t.Fatalf("failed to add subscription (%v)", err)
+				}
+			}
+
+			meta := make([][]Message, 0)
+			d := newDispatcher(subscriptions, newNullLogger(), make(chan struct{}))
+			d.Handle(MetaConnect, func(ms []Message) {
+				meta = append(meta, ms)
+			})
+			d.Dispatch(tc.messages)
+
+			for channel, msgs := range msgChans {
+				got := received(msgs)
+				expected := tc.expected[channel]
+				if expected == nil {
+					expected = [][]Message{}
+				}
+				if !reflect.DeepEqual(got, expected) {
+					t.Errorf("expected %s to receive %v but got %v", channel, expected, got)
+				}
+			}
+			if tc.meta == nil {
+				tc.meta = [][]Message{}
+			}
+			if !reflect.DeepEqual(meta, tc.meta) {
+				t.Errorf("expected meta handler to receive %v but got %v", tc.meta, meta)
+			}
+		})
+	}
+}
+
+func TestDispatcher_DeliverToOverlappingSubscriptions(t *testing.T) {
+	subscriptions := newSubscriptionsMap()
+	msgChans := map[Channel]chan []Message{
+		"/foo/*":   make(chan []Message, 1),
+		"/foo/**":  make(chan []Message, 1),
+		"/foo/bar": make(chan []Message, 1),
+		"/foo/baz": make(chan []Message, 1),
+	}
+	for channel, msgs := range msgChans {
+		if _, err := subscriptions.Add(channel, newSubscriber(msgs)); err != nil {
+			t.Fatalf("failed to add subscription (%v)", err)
+		}
+	}
+
+	d := newDispatcher(subscriptions, newNullLogger(), make(chan struct{}))
+	d.Dispatch([]Message{{Channel: "/foo/bar"}})
+
+	for channel, msgs := range msgChans {
+		select {
+		case batch := <-msgs:
+			if channel == "/foo/baz" {
+				t.Errorf("unexpected delivery to %s", channel)
+			} else if len(batch) != 1 || batch[0].Channel != "/foo/bar" {
+				t.Errorf("unexpected batch delivered to %s: %v", channel, batch)
+			}
+		default:
+			if channel != "/foo/baz" {
+				t.Errorf("expected delivery to %s", channel)
+			}
+		}
+	}
+}
+
+func TestDispatcher_StopsBlockedDelivery(t *testing.T) {
+	subscriptions := newSubscriptionsMap()
+	// Nobody reads from this subscriber
+	if _, err := subscriptions.Add("/foo", newSubscriber(make(chan []Message))); err != nil {
+		t.Fatalf("failed to add subscription (%v)", err)
+	}
+
+	stopped := make(chan struct{})
+	d := newDispatcher(subscriptions, newNullLogger(), stopped)
+	done := make(chan struct{})
+	go func() {
+		d.Dispatch([]Message{{Channel: "/foo"}, {Channel: "/foo"}})
+		close(done)
+	}()
+
+	close(stopped)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected Dispatch to return once stopped")
+	}
+}
@@ -23,6 +23,21 @@
 //		recv := make(chan []gobayeux.Message)
 //		client.Subscribe("example-channel", recv)
 //
+// Or register any number of listeners on a channel with a callback. The
+// client only unsubscribes from the channel once every listener has left
+//
+//		sub, err := client.On("/example-channel/*", func(ctx context.Context, m gobayeux.Message) {
+//			fmt.Println(string(m.Data))
+//		})
+//		defer sub.Unsubscribe()
+//
+// You can publish messages to a Bayeux Channel once the client has started.
+// Publishing happens independently of the long-polling loop
+//
+//		err := client.Publish(ctx, []gobayeux.Message{
+//			{Channel: "/example-channel", Data: json.RawMessage(`{"hello":"world"}`)},
+//		})
+//
 // You can also register extensions that you'd like to use with the server
 // by implementing the MessageExtender interface and then passing it to the
 // client
@@ -17,6 +17,10 @@ const (
 	// ErrFailedToConnect is a general connection error
 	ErrFailedToConnect = sentinel("connect request was not successful")
 
+	// ErrMustNotReconnect is returned when the server advises that the
+	// client must neither retry nor handshake again
+	ErrMustNotReconnect = sentinel("server advised the client not to reconnect")
+
 	// ErrNoSupportedConnectionTypes is returned when the client and server
 	// aren't able to agree on a connection type
 	ErrNoSupportedConnectionTypes = sentinel("no supported connection types provided")
@@ -29,6 +33,17 @@ const (
 
 	// ErrMissingConnectionType is returned when the connection type is unset
 	ErrMissingConnectionType = sentinel("missing connectionType value")
+
+	// ErrConnectionClosed is returned when the connection to the server is
+	// lost while waiting for a reply
+	ErrConnectionClosed = sentinel("connection to server closed")
+
+	// ErrMissingData is returned when a message to be published has no data
+	ErrMissingData = sentinel("missing data value")
+
+	// ErrCallAbandoned is returned by Call when the session it was made in
+	// ends before the reply arrives
+	ErrCallAbandoned = sentinel("call abandoned as the session ended")
 )
 
 type sentinel string
@@ -63,9 +78,9 @@ func (e HandshakeFailedError) Unwrap() error {
 	return e.Err
 }
 
-func newHandshakeError(msg string) *HandshakeFailedError {
+func newHandshakeError(reply Message) *HandshakeFailedError {
 	return &HandshakeFailedError{
-		fmt.Errorf("handshake was not successful: %s", msg),
+		UnsuccessfulReplyError{reply, fmt.Errorf("handshake was not successful: %s", reply.Error)},
 	}
 }
 
@@ -97,6 +112,20 @@ func (e UnsubscribeFailedError) Unwrap() error {
 	return e.Err
 }
 
+// PublishFailedError is returned for any errors on Publish
+type PublishFailedError struct {
+	Channels []Channel
+	Err      error
+}
+
+func (e PublishFailedError) Error() string {
+	return fmt.Sprintf("publish failed (%s)", e.Err)
+}
+
+func (e PublishFailedError) Unwrap() error {
+	return e.Err
+}
+
 // ActionFailedError is a general purpose error returned by the BayeuxClient
 type ActionFailedError struct {
 	Action       string
@@ -107,12 +136,49 @@ func (e ActionFailedError) Error() string {
 	return fmt.Sprintf("unable to %s channels: %s", e.Action, e.ErrorMessage)
 }
 
-func newSubscribeError(msg string) *ActionFailedError {
-	return &ActionFailedError{"subscribe to", msg}
+func newSubscribeError(reply Message) error {
+	return UnsuccessfulReplyError{reply, &ActionFailedError{"subscribe to", reply.Error}}
+}
+
+func newUnsubscribeError(reply Message) error {
+	return UnsuccessfulReplyError{reply, &ActionFailedError{"unsubscribe from", reply.Error}}
+}
+
+func newPublishError(reply Message) error {
+	return UnsuccessfulReplyError{reply, &ActionFailedError{"publish to", reply.Error}}
+}
+
+// UnsuccessfulReplyError is wrapped by the errors returned when the server
+// replies unsuccessfully so that the reply can be inspected with errors.As
+type UnsuccessfulReplyError struct {
+	Reply Message
+	Err   error
+}
+
+func (e UnsuccessfulReplyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e UnsuccessfulReplyError) Unwrap() error {
+	return e.Err
 }
 
-func newUnsubscribeError(msg string) *ActionFailedError {
-	return &ActionFailedError{"unsubscribe from", msg}
+// CallFailedError is returned when the reply to Call is unsuccessful
+type CallFailedError struct {
+	Channel Channel
+	Err     error
+}
+
+func (e CallFailedError) Error() string {
+	return fmt.Sprintf("call failed (%s)", e.Err)
+}
+
+func (e CallFailedError) Unwrap() error {
+	return e.Err
+}
+
+func newCallError(reply Message) error {
+	return UnsuccessfulReplyError{reply, &ActionFailedError{"call", reply.Error}}
 }
 
 // DisconnectFailedError is returned when the call to Disconnect fails
@@ -134,16 +200,45 @@ func (e DisconnectFailedError) Unwrap() error {
 	return e.Err
 }
 
-// AlreadyRegisteredError signifies that the given MessageExtender is already
-// registered with the client
+// AlreadyRegisteredError signifies that the given MessageExtender or
+// Extension is already registered with the client
 type AlreadyRegisteredError struct {
 	MessageExtender
+	Extension Extension
 }
 
 func (e AlreadyRegisteredError) Error() string {
+	if e.MessageExtender == nil {
+		return fmt.Sprintf("extension already registered: %s", e.Extension)
+	}
 	return fmt.Sprintf("extension already registered: %s", e.MessageExtender)
 }
 
+// NotRegisteredError signifies that the given extension is not registered
+// with the client
+type NotRegisteredError struct {
+	ExtensionLifecycle
+}
+
+func (e NotRegisteredError) Error() string {
+	return fmt.Sprintf("extension not registered: %s", e.ExtensionLifecycle)
+}
+
+// ExtensionFailedError is returned when an Extension fails a request by
+// returning an error for one of its messages
+type ExtensionFailedError struct {
+	ExtensionName string
+	Err           error
+}
+
+func (e ExtensionFailedError) Error() string {
+	return fmt.Sprintf("extension %q failed (%s)", e.ExtensionName, e.Err)
+}
+
+func (e ExtensionFailedError) Unwrap() error {
+	return e.Err
+}
+
 // BadResponseError is returned when we get an unexpected HTTP response from the server
 type BadResponseError struct {
 	StatusCode int
@@ -160,8 +255,21 @@ func (e BadResponseError) Error() string {
 	)
 }
 
-// BadConnectionTypeError is returned when we don't know how to handle the
-// requested connection type
+// UnexpectedReplyError is returned when the server replies with an id that
+// does not belong to any of the requests it is replying to
+type UnexpectedReplyError struct {
+	Channel Channel
+	ID      string
+	// Reply is the unexpected reply
+	Reply Message
+}
+
+func (e UnexpectedReplyError) Error() string {
+	return fmt.Sprintf("reply on %q has unexpected id %q", e.Channel, e.ID)
+}
+
+// BadConnectionTypeError is returned when a connection type is not a valid
+// name
 type BadConnectionTypeError struct {
 	ConnectionType string
 }
@@ -255,3 +363,18 @@ type UnknownEventTypeError struct {
 func (e UnknownEventTypeError) Error() string {
 	return fmt.Sprintf("unknown event type (%q)", e.Event)
 }
+
+// DecodeError is sent by SubscribeTyped for each message whose Data could not
+// be decoded
+type DecodeError struct {
+	Message Message
+	Err     error
+}
+
+func (e DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode data on %s (%s)", e.Message.Channel, e.Err)
+}
+
+func (e DecodeError) Unwrap() error {
+	return e.Err
+}
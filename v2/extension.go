@@ -1,10 +1,176 @@
 package gobayeux
 
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// ExtensionLifecycle is implemented by every kind of extension so that it can
+// be told when it is added to or removed from a BayeuxClient
+type ExtensionLifecycle interface {
+	Registered(extensionName string, client *BayeuxClient)
+	Unregistered()
+}
+
 // MessageExtender defines the interface that extensions are expected to
 // implement
 type MessageExtender interface {
 	Outgoing(*Message)
 	Incoming(*Message)
-	Registered(extensionName string, client *BayeuxClient)
-	Unregistered()
+	ExtensionLifecycle
+}
+
+// Extension is an extension that may drop messages or fail the request
+// they are part of. Extensions are registered with AddExtension.
+//
+// Outgoing is called with each message before it is sent and Incoming with
+// each message received. Both return whether to keep the message; dropped
+// messages are not passed to any later extension. Returning an error aborts
+// the request with an ExtensionFailedError.
+type Extension interface {
+	Outgoing(*Message) (bool, error)
+	Incoming(*Message) (bool, error)
+	ExtensionLifecycle
+}
+
+// NamedExtension may be implemented by an extension to choose the name
+// passed to Registered. Otherwise the extension's type name is used.
+type NamedExtension interface {
+	ExtensionName() string
+}
+
+// AdaptMessageExtender returns an Extension that runs ext and keeps every
+// message
+func AdaptMessageExtender(ext MessageExtender) Extension {
+	return messageExtenderAdapter{ext}
+}
+
+type messageExtenderAdapter struct {
+	MessageExtender
+}
+
+func (a messageExtenderAdapter) Outgoing(m *Message) (bool, error) {
+	a.MessageExtender.Outgoing(m)
+	return true, nil
+}
+
+func (a messageExtenderAdapter) Incoming(m *Message) (bool, error) {
+	a.MessageExtender.Incoming(m)
+	return true, nil
+}
+
+func (a messageExtenderAdapter) ExtensionName() string {
+	return extensionName(a.MessageExtender)
+}
+
+// extensionName returns the name ext is registered under
+func extensionName(ext ExtensionLifecycle) string {
+	if named, ok := ext.(NamedExtension); ok {
+		return named.ExtensionName()
+	}
+	return fmt.Sprintf("%T", ext)
+}
+
+// registeredExtension is an Extension along with what was registered, which
+// differs when a MessageExtender was adapted
+type registeredExtension struct {
+	name       string
+	ext        Extension
+	registered ExtensionLifecycle
+}
+
+// extensionPipeline runs every registered Extension over each message in a
+// batch. Outgoing messages pass through extensions in the order they were
+// registered and incoming messages in the reverse order, so that the first
+// extension registered is the first to see what we send and the last to see
+// what we receive.
+//
+// See also: https://docs.cometd.org/current/reference/#_extensions
+type extensionPipeline struct {
+	lock sync.RWMutex
+	exts []registeredExtension
+}
+
+// Add registers ext, which is what the caller registered, to run as adapted
+func (p *extensionPipeline) Add(ext ExtensionLifecycle, adapted Extension) (string, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.index(ext) != -1 {
+		if extender, ok := ext.(MessageExtender); ok {
+			return "", AlreadyRegisteredError{MessageExtender: extender}
+		}
+		return "", AlreadyRegisteredError{Extension: adapted}
+	}
+	name := extensionName(ext)
+	p.exts = append(p.exts, registeredExtension{name, adapted, ext})
+	return name, nil
+}
+
+func (p *extensionPipeline) Remove(ext ExtensionLifecycle) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	i := p.index(ext)
+	if i == -1 {
+		return NotRegisteredError{ext}
+	}
+	// Copy so that batches already running keep the extensions they
+	// started with
+	p.exts = slices.Delete(slices.Clone(p.exts), i, i+1)
+	return nil
+}
+
+// index must be called with p.lock held
+func (p *extensionPipeline) index(ext ExtensionLifecycle) int {
+	return slices.IndexFunc(p.exts, func(r registeredExtension) bool {
+		return r.registered == ext
+	})
+}
+
+func (p *extensionPipeline) extensions() []registeredExtension {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.exts
+}
+
+// Outgoing applies every extension to each message in ms in place and
+// returns the messages that should be sent
+func (p *extensionPipeline) Outgoing(ms []Message) ([]Message, error) {
+	exts := p.extensions()
+	kept := make([]Message, 0, len(ms))
+_messages:
+	for i := range ms {
+		for _, r := range exts {
+			keep, err := r.ext.Outgoing(&ms[i])
+			if err != nil {
+				return nil, ExtensionFailedError{r.name, err}
+			}
+			if !keep {
+				continue _messages
+			}
+		}
+		kept = append(kept, ms[i])
+	}
+	return kept, nil
+}
+
+// Incoming applies every extension, in reverse order, to each message in ms
+// in place and returns the messages that should be kept
+func (p *extensionPipeline) Incoming(ms []Message) ([]Message, error) {
+	exts := p.extensions()
+	kept := make([]Message, 0, len(ms))
+_messages:
+	for i := range ms {
+		for j := len(exts) - 1; j >= 0; j-- {
+			keep, err := exts[j].ext.Incoming(&ms[i])
+			if err != nil {
+				return nil, ExtensionFailedError{exts[j].name, err}
+			}
+			if !keep {
+				continue _messages
+			}
+		}
+		kept = append(kept, ms[i])
+	}
+	return kept, nil
 }
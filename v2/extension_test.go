@@ -0,0 +1,190 @@
+package gobayeux
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+)
+
+// recordingExtension appends its name to the ID of every message it sees so
+// that the order extensions run in can be observed
+type recordingExtension struct {
+	name         string
+	registeredAs string
+	client       *BayeuxClient
+	unregistered bool
+}
+
+func (e *recordingExtension) Outgoing(m *Message) {
+	m.ID += e.name
+}
+
+func (e *recordingExtension) Incoming(m *Message) {
+	m.ID += e.name
+}
+
+func (e *recordingExtension) Registered(extensionName string, client *BayeuxClient) {
+	e.registeredAs = extensionName
+	e.client = client
+}
+
+func (e *recordingExtension) Unregistered() {
+	e.unregistered = true
+}
+
+type namedExtension struct {
+	recordingExtension
+}
+
+func (e *namedExtension) ExtensionName() string {
+	return "named"
+}
+
+func TestBayeuxClient_ExtensionPipeline(t *testing.T) {
+	transport := &fakeTransport{
+		connectionType: ConnectionTypeLongPolling,
+		reply: func(ms []Message) []Message {
+			return []Message{{Channel: "/foo/bar", ID: "in:"}}
+		},
+	}
+	b, err := NewBayeuxClient(nil, nil, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	first := &recordingExtension{name: "a"}
+	second := &namedExtension{recordingExtension{name: "b"}}
+	for _, ext := range []MessageExtender{first, second} {
+		if err := b.UseExtension(ext); err != nil {
+			t.Fatalf("failed to register extension (%v)", err)
+		}
+	}
+	if err := b.UseExtension(first); !errors.As(err, &AlreadyRegisteredError{}) {
+		t.Errorf("expected AlreadyRegisteredError registering twice but got %v", err)
+	}
+
+	if first.registeredAs != "*gobayeux.recordingExtension" || first.client != b {
+		t.Errorf("unexpected registration %q, %p", first.registeredAs, first.client)
+	}
+	if second.registeredAs != "named" {
+		t.Errorf("expected extension to be registered as %q but got %q", "named", second.registeredAs)
+	}
+
+	replies, err := b.send(context.Background(), transport, []Message{{Channel: "/foo/bar", ID: "out:"}, {Channel: "/foo/baz", ID: "out:"}})
+	if err != nil {
+		t.Fatalf("unexpected error sending (%v)", err)
+	}
+	sent := transport.sent[0]
+	if got := []string{sent[0].ID, sent[1].ID}; !slices.Equal(got, []string{"out:ab", "out:ab"}) {
+		t.Errorf("expected outgoing extensions in registration order but got %v", got)
+	}
+	if got := replies[0].ID; got != "in:ba" {
+		t.Errorf("expected incoming extensions in reverse order but got %q", got)
+	}
+
+	if err := b.RemoveExtension(first); err != nil {
+		t.Fatalf("failed to remove extension (%v)", err)
+	}
+	if !first.unregistered {
+		t.Error("expected Unregistered to be called")
+	}
+	if err := b.RemoveExtension(first); !errors.As(err, &NotRegisteredError{}) {
+		t.Errorf("expected NotRegisteredError removing twice but got %v", err)
+	}
+
+	replies, err = b.send(context.Background(), transport, []Message{{Channel: "/foo/bar", ID: "out:"}})
+	if err != nil {
+		t.Fatalf("unexpected error sending (%v)", err)
+	}
+	if got := transport.sent[1][0].ID; got != "out:b" {
+		t.Errorf("expected only the remaining extension to run but got %q", got)
+	}
+	if got := replies[0].ID; got != "in:b" {
+		t.Errorf("expected only the remaining extension to run but got %q", got)
+	}
+}
+
+// filteringExtension drops messages on one channel and fails messages on
+// another
+type filteringExtension struct {
+	drop Channel
+	fail Channel
+}
+
+func (e *filteringExtension) Outgoing(m *Message) (bool, error) {
+	return e.filter(m)
+}
+
+func (e *filteringExtension) Incoming(m *Message) (bool, error) {
+	return e.filter(m)
+}
+
+func (e *filteringExtension) filter(m *Message) (bool, error) {
+	switch m.Channel {
+	case e.fail:
+		return false, errors.New("bad signature")
+	case e.drop:
+		return false, nil
+	}
+	return true, nil
+}
+
+func (e *filteringExtension) Registered(string, *BayeuxClient) {}
+
+func (e *filteringExtension) Unregistered() {}
+
+func TestBayeuxClient_ExtensionsDropAndFail(t *testing.T) {
+	transport := &fakeTransport{
+		connectionType: ConnectionTypeLongPolling,
+		reply: func(ms []Message) []Message {
+			return []Message{{Channel: "/heartbeat"}, {Channel: "/foo/bar"}}
+		},
+	}
+	b, err := NewBayeuxClient(nil, nil, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	recorder := &recordingExtension{name: "a"}
+	filter := &filteringExtension{drop: "/heartbeat", fail: "/forged"}
+	if err := b.UseExtension(recorder); err != nil {
+		t.Fatalf("failed to register extension (%v)", err)
+	}
+	if err := b.AddExtension(filter); err != nil {
+		t.Fatalf("failed to register extension (%v)", err)
+	}
+	if err := b.AddExtension(filter); !errors.As(err, &AlreadyRegisteredError{}) {
+		t.Errorf("expected AlreadyRegisteredError registering twice but got %v", err)
+	}
+
+	replies, err := b.send(context.Background(), transport, []Message{{Channel: "/heartbeat"}, {Channel: "/foo/bar"}})
+	if err != nil {
+		t.Fatalf("unexpected error sending (%v)", err)
+	}
+	if sent := transport.sent[0]; len(sent) != 1 || sent[0].Channel != "/foo/bar" {
+		t.Errorf("expected only /foo/bar to be sent but got %v", sent)
+	}
+	if len(replies) != 1 || replies[0].Channel != "/foo/bar" {
+		t.Fatalf("expected only /foo/bar to be received but got %v", replies)
+	}
+	// Incoming extensions run in reverse so the recorder never sees the
+	// dropped heartbeat
+	if replies[0].ID != "a" {
+		t.Errorf("expected the recorder to see the kept reply but got %q", replies[0].ID)
+	}
+
+	_, err = b.send(context.Background(), transport, []Message{{Channel: "/foo/bar"}, {Channel: "/forged"}})
+	var extErr ExtensionFailedError
+	if !errors.As(err, &extErr) || extErr.ExtensionName != "*gobayeux.filteringExtension" {
+		t.Fatalf("expected an ExtensionFailedError but got %v", err)
+	}
+	if len(transport.sent) != 1 {
+		t.Error("expected the failed request not to be sent")
+	}
+
+	if err := b.RemoveExtension(filter); err != nil {
+		t.Fatalf("failed to remove extension (%v)", err)
+	}
+	if _, err := b.send(context.Background(), transport, []Message{{Channel: "/forged"}}); err != nil {
+		t.Errorf("expected no error after removing the extension but got %v", err)
+	}
+}
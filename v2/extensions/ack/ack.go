@@ -0,0 +1,172 @@
+// Package ack provides the message acknowledgement extension for the Bayeux
+// protocol.
+//
+// When the server supports it, each /meta/connect reply carries the id of the
+// batch of messages it delivered and the next /meta/connect request echoes
+// that id back. The server keeps every batch that has not been acknowledged
+// and delivers it again, so messages are not lost when a long poll breaks.
+// Messages that arrive twice as a result are dropped by the extension.
+//
+// Example Usage:
+//
+//	client := gobayeux.NewClient(serverAddress)
+//	client.AddExtension(ack.New())
+//
+// See also: https://docs.cometd.org/current/reference/#_extensions_acknowledge
+package ack
+
+import (
+	"sync"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+)
+
+const (
+	// ExtensionName is the name of the acknowledgement extension in the
+	// ext field of messages
+	ExtensionName string = "ack"
+	// DefaultWindow is how many message ids are remembered to detect
+	// redelivered messages
+	DefaultWindow int = 1000
+)
+
+// Extension implements the acknowledgement extension. It must be registered
+// with AddExtension so that it can drop redelivered messages.
+type Extension struct {
+	mu        sync.Mutex
+	supported bool
+	batch     int64
+	window    int
+	seen      map[string]struct{}
+	order     []string
+}
+
+var _ bayeux.Extension = (*Extension)(nil)
+
+// New creates a new extension which remembers the last DefaultWindow message
+// ids
+func New() *Extension {
+	return NewWithWindow(DefaultWindow)
+}
+
+// NewWithWindow creates a new extension which remembers the last window
+// message ids to detect redelivered messages
+func NewWithWindow(window int) *Extension {
+	if window <= 0 {
+		window = DefaultWindow
+	}
+	return &Extension{
+		window: window,
+		seen:   make(map[string]struct{}, window),
+	}
+}
+
+// ExtensionName implements the gobayeux.NamedExtension interface
+func (e *Extension) ExtensionName() string {
+	return ExtensionName
+}
+
+// Outgoing asks for acknowledgements during the handshake and then
+// acknowledges the last batch received with each /meta/connect
+func (e *Extension) Outgoing(m *bayeux.Message) (bool, error) {
+	switch m.Channel {
+	case bayeux.MetaHandshake:
+		ext := m.GetExt(true)
+		ext[ExtensionName] = true
+	case bayeux.MetaConnect:
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		if e.supported {
+			ext := m.GetExt(true)
+			ext[ExtensionName] = e.batch
+		}
+	}
+	return true, nil
+}
+
+// Incoming records whether the server supports acknowledgements and the
+// batch id of each /meta/connect reply, and drops messages that have already
+// been delivered
+func (e *Extension) Incoming(m *bayeux.Message) (bool, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	switch m.Channel {
+	case bayeux.MetaHandshake:
+		if !m.Successful {
+			return true, nil
+		}
+		// A new session starts with nothing delivered
+		supported, _ := m.GetExt(false)[ExtensionName].(bool)
+		e.supported = supported
+		e.batch = 0
+		clear(e.seen)
+		e.order = e.order[:0]
+	case bayeux.MetaConnect:
+		if !m.Successful || !e.supported {
+			return true, nil
+		}
+		if batch, ok := batchID(m.GetExt(false)[ExtensionName]); ok {
+			e.batch = batch
+		}
+	default:
+		if m.Channel.Type() == bayeux.MetaChannel || len(m.Data) == 0 || m.ID == "" {
+			// Only messages delivered to us are redelivered
+			return true, nil
+		}
+		return e.remember(m.ID), nil
+	}
+	return true, nil
+}
+
+// Registered is called after an extension has been successfully registered
+func (e *Extension) Registered(extensionName string, client *bayeux.BayeuxClient) {
+}
+
+// Unregistered is called when an extension is unregistered
+func (e *Extension) Unregistered() {
+}
+
+// Supported reports whether the server agreed to acknowledge messages during
+// the last handshake
+func (e *Extension) Supported() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.supported
+}
+
+// Batch returns the id of the last batch of messages received, which is
+// acknowledged by the next /meta/connect request
+func (e *Extension) Batch() int64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.batch
+}
+
+// remember records id and reports whether it is new. It must be called with
+// e.mu held.
+func (e *Extension) remember(id string) bool {
+	if _, ok := e.seen[id]; ok {
+		return false
+	}
+	if len(e.order) == e.window {
+		delete(e.seen, e.order[0])
+		e.order = e.order[1:]
+	}
+	e.seen[id] = struct{}{}
+	e.order = append(e.order, id)
+	return true
+}
+
+// batchID converts the value of ext.ack decoded from JSON
+func batchID(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	}
+	return 0, false
+}
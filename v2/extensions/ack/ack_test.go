@@ -0,0 +1,111 @@
+package ack
+
+import (
+	"encoding/json"
+	"testing"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+)
+
+func handshake(t *testing.T, e *Extension, supported bool) {
+	t.Helper()
+	m := bayeux.Message{Channel: bayeux.MetaHandshake}
+	if _, err := e.Outgoing(&m); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	if v, ok := m.Ext[ExtensionName].(bool); !ok || !v {
+		t.Fatal("ack extension was not requested in the handshake")
+	}
+
+	reply := bayeux.Message{Channel: bayeux.MetaHandshake, Successful: true}
+	if supported {
+		reply.Ext = map[string]interface{}{ExtensionName: true}
+	}
+	if _, err := e.Incoming(&reply); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+}
+
+func TestUnsupportedByServer(t *testing.T) {
+	e := New()
+	handshake(t, e, false)
+	if e.Supported() {
+		t.Fatal("expected the extension to be unsupported")
+	}
+
+	m := bayeux.Message{Channel: bayeux.MetaConnect}
+	if _, err := e.Outgoing(&m); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	if _, ok := m.Ext[ExtensionName]; ok {
+		t.Error("expected no ack on /meta/connect when unsupported")
+	}
+}
+
+func TestAcknowledgesLastBatch(t *testing.T) {
+	e := New()
+	handshake(t, e, true)
+
+	// Batch ids are decoded from JSON as float64
+	var reply bayeux.Message
+	if err := json.Unmarshal([]byte(`{"channel":"/meta/connect","successful":true,"ext":{"ack":42}}`), &reply); err != nil {
+		t.Fatalf("failed to decode reply (%v)", err)
+	}
+	if _, err := e.Incoming(&reply); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	if got := e.Batch(); got != 42 {
+		t.Fatalf("expected batch 42 but got %d", got)
+	}
+
+	// A failed connect does not move the batch on so that the server
+	// redelivers it
+	failed := bayeux.Message{Channel: bayeux.MetaConnect, Ext: map[string]interface{}{ExtensionName: float64(43)}}
+	if _, err := e.Incoming(&failed); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	m := bayeux.Message{Channel: bayeux.MetaConnect}
+	if _, err := e.Outgoing(&m); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	if got := m.Ext[ExtensionName]; got != int64(42) {
+		t.Errorf("expected to acknowledge batch 42 but got %v", got)
+	}
+
+	// A new session starts over
+	handshake(t, e, true)
+	if got := e.Batch(); got != 0 {
+		t.Errorf("expected batch to reset after handshake but got %d", got)
+	}
+}
+
+func TestDropsRedeliveredMessages(t *testing.T) {
+	e := NewWithWindow(2)
+	handshake(t, e, true)
+
+	testCases := []struct {
+		name    string
+		message bayeux.Message
+		keep    bool
+	}{
+		{"first delivery", bayeux.Message{Channel: "/foo", ID: "1", Data: json.RawMessage(`{}`)}, true},
+		{"redelivery", bayeux.Message{Channel: "/foo", ID: "1", Data: json.RawMessage(`{}`)}, false},
+		{"publish reply", bayeux.Message{Channel: "/foo", ID: "1", Successful: true}, true},
+		{"second delivery", bayeux.Message{Channel: "/foo", ID: "2", Data: json.RawMessage(`{}`)}, true},
+		{"third delivery", bayeux.Message{Channel: "/foo", ID: "3", Data: json.RawMessage(`{}`)}, true},
+		{"forgotten outside window", bayeux.Message{Channel: "/foo", ID: "1", Data: json.RawMessage(`{}`)}, true},
+		{"no id", bayeux.Message{Channel: "/foo", Data: json.RawMessage(`{}`)}, true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		keep, err := e.Incoming(&tc.message)
+		if err != nil {
+			t.Fatalf("%s: unexpected error (%v)", tc.name, err)
+		}
+		if keep != tc.keep {
+			t.Errorf("%s: expected keep to be %t but got %t", tc.name, tc.keep, keep)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Package boltstore provides a replay.IDStore backed by bbolt, a pure Go
+// embedded key-value database, so that replay IDs survive restarts.
+//
+// Example Usage:
+//
+//	store, err := boltstore.Open("replay.db")
+//	if err != nil {
+//		return err
+//	}
+//	defer store.Close()
+//	client := gobayeux.NewClient(serverAddress)
+//	client.UseExtension(replay.New(store))
+package boltstore
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2/extensions/replay"
+	bolt "go.etcd.io/bbolt"
+)
+
+// DefaultBucket is the bucket replay IDs are stored in unless another is
+// chosen with WithBucket
+const DefaultBucket = "replay"
+
+// Options stores the configuration for a Storage
+type Options struct {
+	// Bucket is the name of the bucket replay IDs are stored in
+	Bucket string
+	// OnError is called with any error updating the database in the
+	// course of Set or Delete, which cannot return one
+	OnError func(error)
+	// Bolt is passed to bolt.Open by Open
+	Bolt *bolt.Options
+}
+
+// Option defines the type passed into Open and New for configuration
+type Option func(*Options)
+
+// WithBucket returns an Option with bucket
+func WithBucket(bucket string) Option {
+	return func(o *Options) {
+		o.Bucket = bucket
+	}
+}
+
+// WithErrorHandler returns an Option with handler
+func WithErrorHandler(handler func(error)) Option {
+	return func(o *Options) {
+		o.OnError = handler
+	}
+}
+
+// WithBoltOptions returns an Option with the options used to open the
+// database
+func WithBoltOptions(options *bolt.Options) Option {
+	return func(o *Options) {
+		o.Bolt = options
+	}
+}
+
+// Storage implements the replay.IDStore interface over a bucket in a bbolt
+// database. Every Set and Delete is committed in its own transaction, which
+// bbolt serialises and fsyncs.
+type Storage struct {
+	db      *bolt.DB
+	bucket  []byte
+	onError func(error)
+	ownsDB  bool
+}
+
+var _ replay.IDStore = (*Storage)(nil)
+
+// Open opens, creating it if needed, the database at path and returns a
+// Storage over it. Closing the Storage closes the database.
+func Open(path string, opts ...Option) (*Storage, error) {
+	options := newOptions(opts)
+	boltOptions := options.Bolt
+	if boltOptions == nil {
+		// Fail rather than hang when another process has the
+		// database open
+		boltOptions = &bolt.Options{Timeout: time.Second}
+	}
+	db, err := bolt.Open(path, 0o600, boltOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := newStorage(db, options)
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	s.ownsDB = true
+	return s, nil
+}
+
+// New returns a Storage over a database which is already open. Closing the
+// Storage leaves the database open.
+func New(db *bolt.DB, opts ...Option) (*Storage, error) {
+	return newStorage(db, newOptions(opts))
+}
+
+func newOptions(opts []Option) Options {
+	options := Options{Bucket: DefaultBucket}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+func newStorage(db *bolt.DB, options Options) (*Storage, error) {
+	bucket := []byte(options.Bucket)
+	err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Storage{db: db, bucket: bucket, onError: options.OnError}, nil
+}
+
+// Set implements the replay.IDStore interface
+func (s *Storage) Set(channel string, replayID int64) {
+	s.update(func(b *bolt.Bucket) error {
+		return b.Put([]byte(channel), strconv.AppendInt(nil, replayID, 10))
+	})
+}
+
+// Get implements the replay.IDStore interface
+func (s *Storage) Get(channel string) (replayID int64, ok bool) {
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		replayID, ok = parse(tx.Bucket(s.bucket).Get([]byte(channel)))
+		return nil
+	})
+	return
+}
+
+// Delete implements the replay.IDStore interface
+func (s *Storage) Delete(channel string) {
+	s.update(func(b *bolt.Bucket) error {
+		return b.Delete([]byte(channel))
+	})
+}
+
+// AsMap implements the replay.IDStore interface
+func (s *Storage) AsMap() map[string]int64 {
+	replay := make(map[string]int64)
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(s.bucket).ForEach(func(k, v []byte) error {
+			if replayID, ok := parse(v); ok {
+				replay[string(k)] = replayID
+			}
+			return nil
+		})
+	})
+	return replay
+}
+
+// Close closes the database if it was opened by Open
+func (s *Storage) Close() error {
+	if !s.ownsDB {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func (s *Storage) update(fn func(*bolt.Bucket) error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return fn(tx.Bucket(s.bucket))
+	})
+	if err != nil && s.onError != nil {
+		s.onError(err)
+	}
+}
+
+func parse(value []byte) (int64, bool) {
+	if value == nil {
+		return 0, false
+	}
+	replayID, err := strconv.ParseInt(string(value), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return replayID, true
+}
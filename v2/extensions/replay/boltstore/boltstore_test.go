@@ -0,0 +1,112 @@
+package boltstore
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestStoragePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	if _, ok := s.Get("/foo/bar"); ok {
+		t.Error("expected an empty store")
+	}
+	s.Set("/foo/bar", 1234)
+	s.Set("/foo/baz", -2)
+	s.Delete("/foo/baz")
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error closing storage (%v)", err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	defer reopened.Close()
+	if got, ok := reopened.Get("/foo/bar"); !ok || got != 1234 {
+		t.Errorf("expected 1234 but got %d", got)
+	}
+	got := reopened.AsMap()
+	if len(got) != 1 || got["/foo/bar"] != 1234 {
+		t.Errorf("expected only /foo/bar to be stored but got %v", got)
+	}
+}
+
+func TestNewSharesDatabase(t *testing.T) {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "app.db"), 0o600, nil)
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	defer db.Close()
+
+	first, err := New(db, WithBucket("first"))
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	second, err := New(db, WithBucket("second"))
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	first.Set("/foo/bar", 1)
+	if _, ok := second.Get("/foo/bar"); ok {
+		t.Error("expected buckets to be independent")
+	}
+
+	if err := first.Close(); err != nil {
+		t.Fatalf("unexpected error closing storage (%v)", err)
+	}
+	// The database belongs to the caller so it is still usable
+	second.Set("/foo/bar", 2)
+	if got, _ := second.Get("/foo/bar"); got != 2 {
+		t.Errorf("expected 2 but got %d", got)
+	}
+}
+
+func TestStorageReportsErrors(t *testing.T) {
+	var reported error
+	s, err := Open(filepath.Join(t.TempDir(), "replay.db"), WithErrorHandler(func(err error) { reported = err }))
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error closing storage (%v)", err)
+	}
+	s.Set("/foo/bar", 1)
+	if reported == nil {
+		t.Error("expected the error to be reported")
+	}
+}
+
+func TestStorageConcurrentSet(t *testing.T) {
+	s, err := Open(filepath.Join(t.TempDir(), "replay.db"), WithBoltOptions(&bolt.Options{NoSync: true}))
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	defer s.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				s.Set(fmt.Sprintf("/foo/%d", i), int64(j))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	got := s.AsMap()
+	for i := 0; i < 10; i++ {
+		if got[fmt.Sprintf("/foo/%d", i)] != 9 {
+			t.Errorf("expected /foo/%d to be 9 but got %v", i, got)
+		}
+	}
+}
@@ -0,0 +1,20 @@
+module github.com/sigmavirus24/gobayeux/v2/extensions/replay/boltstore
+
+go 1.24.0
+
+require (
+	github.com/sigmavirus24/gobayeux/v2 v2.7.0
+	go.etcd.io/bbolt v1.4.3
+)
+
+require (
+	github.com/sirupsen/logrus v1.9.4 // indirect
+	golang.org/x/net v0.50.0 // indirect
+	golang.org/x/sys v0.41.0 // indirect
+)
+
+// Build against the gobayeux/v2 module in this repository during
+// development. Users of this module ignore the replace directive and get the
+// version required above, the first to use int64 replay IDs, which must be
+// released before this module is.
+replace github.com/sigmavirus24/gobayeux/v2 => ../../..
@@ -0,0 +1,73 @@
+package replay
+
+import (
+	"encoding/json"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+)
+
+// IDExtractor returns the replay ID of a message delivered on a broadcast
+// channel, or false when it does not carry one
+type IDExtractor func(ms *bayeux.Message) (replayID int64, ok bool)
+
+// DefaultExtractor is the IDExtractor used unless another is chosen with
+// WithExtractor. It understands every shape Salesforce delivers events in.
+var DefaultExtractor = FirstOf(EventReplayID, BinaryDataReplayID)
+
+// FirstOf returns an IDExtractor which tries each of extractors in turn and
+// returns the first replay ID found
+func FirstOf(extractors ...IDExtractor) IDExtractor {
+	return func(ms *bayeux.Message) (int64, bool) {
+		for _, extractor := range extractors {
+			if replayID, ok := extractor(ms); ok {
+				return replayID, true
+			}
+		}
+		return 0, false
+	}
+}
+
+// EventReplayID extracts the replay ID from `event.replayId` in the data of
+// a message. Salesforce places it there for Platform Events, Change Data
+// Capture events, PushTopic events and generic streaming events alike, e.g.
+//
+//	{"schema": "...", "payload": {...}, "event": {"replayId": 42}}
+//	{"event": {"type": "created", "replayId": 42}, "sobject": {...}}
+func EventReplayID(ms *bayeux.Message) (int64, bool) {
+	return eventReplayID(ms.Data)
+}
+
+// BinaryDataReplayID extracts the replay ID from `event.replayId` in JSON
+// encoded in the data field of a MessageData
+//
+// See also: https://docs.cometd.org/current/reference/#_concepts_binary_data
+func BinaryDataReplayID(ms *bayeux.Message) (int64, bool) {
+	var md *MessageData
+	if err := json.Unmarshal(ms.Data, &md); err != nil || md == nil {
+		return 0, false
+	}
+	return eventReplayID([]byte(md.Data))
+}
+
+// eventData holds the replay ID as a json.Number so that IDs beyond the
+// precision of a float64 are not truncated
+type eventData struct {
+	Event *struct {
+		ReplayID *json.Number `json:"replayId"`
+	} `json:"event"`
+}
+
+func eventReplayID(data []byte) (int64, bool) {
+	var ed eventData
+	if err := json.Unmarshal(data, &ed); err != nil {
+		return 0, false
+	}
+	if ed.Event == nil || ed.Event.ReplayID == nil {
+		return 0, false
+	}
+	replayID, err := ed.Event.ReplayID.Int64()
+	if err != nil {
+		return 0, false
+	}
+	return replayID, true
+}
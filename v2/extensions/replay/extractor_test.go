@@ -0,0 +1,95 @@
+package replay
+
+import (
+	"encoding/json"
+	"testing"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+)
+
+func TestDefaultExtractor(t *testing.T) {
+	binary, _ := json.Marshal(&MessageData{Data: `{"event": {"replayId": 7}}`})
+
+	testCases := []struct {
+		name     string
+		data     string
+		want     int64
+		expectOK bool
+	}{
+		{
+			name:     "platform event",
+			data:     `{"schema": "abc", "payload": {"Amount__c": 1}, "event": {"replayId": 42, "EventUuid": "uuid"}}`,
+			want:     42,
+			expectOK: true,
+		},
+		{
+			name:     "change data capture",
+			data:     `{"schema": "abc", "payload": {"ChangeEventHeader": {"entityName": "Account"}}, "event": {"replayId": 43}}`,
+			want:     43,
+			expectOK: true,
+		},
+		{
+			name:     "push topic",
+			data:     `{"event": {"type": "created", "createdDate": "2020-05-01T06:28:51.000+0000", "replayId": 44}, "sobject": {"Id": "001"}}`,
+			want:     44,
+			expectOK: true,
+		},
+		{
+			name:     "beyond float64 precision",
+			data:     `{"event": {"replayId": 9007199254740993}}`,
+			want:     9007199254740993,
+			expectOK: true,
+		},
+		{
+			name:     "binary data",
+			data:     string(binary),
+			want:     7,
+			expectOK: true,
+		},
+		{
+			name: "fractional replay id",
+			data: `{"event": {"replayId": 1.5}}`,
+		},
+		{
+			name: "null replay id",
+			data: `{"event": {"replayId": null}}`,
+		},
+		{
+			name: "no event",
+			data: `{"payload": {}}`,
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, ok := DefaultExtractor(&bayeux.Message{Channel: "/event/Foo__e", Data: json.RawMessage(tc.data)})
+			if ok != tc.expectOK {
+				t.Fatalf("expected ok to be %t but got %t", tc.expectOK, ok)
+			}
+			if got != tc.want {
+				t.Errorf("expected replay id %d but got %d", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWithExtractor(t *testing.T) {
+	store := NewMapStorage()
+	e := New(store, WithExtractor(func(ms *bayeux.Message) (int64, bool) {
+		var data struct {
+			ReplayID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(ms.Data, &data); err != nil {
+			return 0, false
+		}
+		return data.ReplayID, true
+	}))
+
+	e.Incoming(&bayeux.Message{Channel: "/foo/bar", Data: json.RawMessage(`{"id": 12}`)})
+	if got, _ := store.Get("/foo/bar"); got != 12 {
+		t.Errorf("expected the custom extractor's replay id 12 but got %d", got)
+	}
+}
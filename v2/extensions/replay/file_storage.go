@@ -0,0 +1,308 @@
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// SyncPolicy controls when FileStorage flushes replay IDs to stable storage
+type SyncPolicy int
+
+const (
+	// SyncAlways writes the file and fsyncs it, and the directory it is in,
+	// on every change. Nothing acknowledged by Set is lost if the machine
+	// crashes, at the cost of an fsync for every message received.
+	SyncAlways SyncPolicy = iota
+	// SyncNever writes the file on every change but leaves flushing it to
+	// disk to the operating system. The file survives the process
+	// crashing but not necessarily the machine crashing.
+	SyncNever
+	// SyncInterval writes and fsyncs the file at most once every
+	// FileStorageOptions.SyncInterval, and when the storage is closed.
+	// Changes made since the last write are lost if the process crashes.
+	SyncInterval
+)
+
+// DefaultSyncInterval is how often FileStorage writes changes when using
+// SyncInterval without choosing an interval
+const DefaultSyncInterval = time.Second
+
+// FileStorageOptions stores the configuration for a FileStorage
+type FileStorageOptions struct {
+	SyncPolicy   SyncPolicy
+	SyncInterval time.Duration
+	// FileMode is the permissions the file is written with
+	FileMode fs.FileMode
+	// OnError is called with any error writing the file in the course of
+	// Set or Delete, which cannot return one
+	OnError func(error)
+}
+
+// FileStorageOption defines the type passed into NewFileStorage for
+// configuration
+type FileStorageOption func(*FileStorageOptions)
+
+// WithSyncPolicy returns a FileStorageOption with policy
+func WithSyncPolicy(policy SyncPolicy) FileStorageOption {
+	return func(o *FileStorageOptions) {
+		o.SyncPolicy = policy
+	}
+}
+
+// WithSyncInterval returns a FileStorageOption which uses the SyncInterval
+// policy with interval
+func WithSyncInterval(interval time.Duration) FileStorageOption {
+	return func(o *FileStorageOptions) {
+		o.SyncPolicy = SyncInterval
+		o.SyncInterval = interval
+	}
+}
+
+// WithFileMode returns a FileStorageOption with mode
+func WithFileMode(mode fs.FileMode) FileStorageOption {
+	return func(o *FileStorageOptions) {
+		o.FileMode = mode
+	}
+}
+
+// WithErrorHandler returns a FileStorageOption with handler
+func WithErrorHandler(handler func(error)) FileStorageOption {
+	return func(o *FileStorageOptions) {
+		o.OnError = handler
+	}
+}
+
+// FileStorage implements the IDStore interface over a map which is persisted
+// to a JSON file. The file is replaced atomically so that it always holds
+// either the old or the new replay IDs, never a partial write.
+type FileStorage struct {
+	path    string
+	options FileStorageOptions
+
+	lock  sync.RWMutex
+	store map[string]int64
+	dirty bool
+
+	// writeLock serialises writes so that the last one to finish always
+	// holds the latest replay IDs
+	writeLock sync.Mutex
+	stop      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFileStorage creates a new FileStorage persisted at path, loading any
+// replay IDs already stored there
+func NewFileStorage(path string, opts ...FileStorageOption) (*FileStorage, error) {
+	options := FileStorageOptions{
+		SyncPolicy: SyncAlways,
+		FileMode:   0o600,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.SyncPolicy == SyncInterval && options.SyncInterval <= 0 {
+		options.SyncInterval = DefaultSyncInterval
+	}
+
+	store := make(map[string]int64)
+	contents, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return nil, err
+	case len(contents) > 0:
+		if err := json.Unmarshal(contents, &store); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &FileStorage{
+		path:    path,
+		options: options,
+		store:   store,
+		stop:    make(chan struct{}),
+		stopped: make(chan struct{}),
+	}
+	if options.SyncPolicy == SyncInterval {
+		go s.syncPeriodically()
+	} else {
+		close(s.stopped)
+	}
+	return s, nil
+}
+
+// Set implements the IDStore interface
+func (s *FileStorage) Set(channel string, replayID int64) {
+	s.lock.Lock()
+	s.store[channel] = replayID
+	s.dirty = true
+	s.lock.Unlock()
+	s.changed()
+}
+
+// Get implements the IDStore interface
+func (s *FileStorage) Get(channel string) (replayID int64, ok bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	replayID, ok = s.store[channel]
+	return
+}
+
+// Delete implements the IDStore interface
+func (s *FileStorage) Delete(channel string) {
+	s.lock.Lock()
+	if _, ok := s.store[channel]; !ok {
+		s.lock.Unlock()
+		return
+	}
+	delete(s.store, channel)
+	s.dirty = true
+	s.lock.Unlock()
+	s.changed()
+}
+
+// AsMap implements the IDStore interface
+func (s *FileStorage) AsMap() map[string]int64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	replay := make(map[string]int64, len(s.store))
+	for k, v := range s.store {
+		replay[k] = v
+	}
+	return replay
+}
+
+// Sync writes any changes not yet written and fsyncs the file regardless of
+// the SyncPolicy
+func (s *FileStorage) Sync() error {
+	return s.write(true)
+}
+
+// Close stops writing changes periodically and writes any outstanding
+// changes. The storage must not be used after it is closed.
+func (s *FileStorage) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.stopped
+	return s.write(s.options.SyncPolicy != SyncNever)
+}
+
+func (s *FileStorage) changed() {
+	if s.options.SyncPolicy == SyncInterval {
+		return
+	}
+	if err := s.write(s.options.SyncPolicy == SyncAlways); err != nil && s.options.OnError != nil {
+		s.options.OnError(err)
+	}
+}
+
+func (s *FileStorage) syncPeriodically() {
+	defer close(s.stopped)
+	ticker := time.NewTicker(s.options.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.lock.RLock()
+			dirty := s.dirty
+			s.lock.RUnlock()
+			if !dirty {
+				continue
+			}
+			if err := s.write(true); err != nil && s.options.OnError != nil {
+				s.options.OnError(err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// write replaces the file with the current replay IDs if they have changed
+// since it was last written
+func (s *FileStorage) write(fsync bool) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+
+	s.lock.Lock()
+	if !s.dirty && !fsync {
+		s.lock.Unlock()
+		return nil
+	}
+	contents, err := json.Marshal(s.store)
+	s.dirty = false
+	s.lock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(s.path, contents, s.options.FileMode, fsync); err != nil {
+		// Try again with the next change
+		s.lock.Lock()
+		s.dirty = true
+		s.lock.Unlock()
+		return err
+	}
+	return nil
+}
+
+// writeFileAtomic writes contents to a temporary file in the same directory
+// as path and renames it over path
+func writeFileAtomic(path string, contents []byte, mode fs.FileMode, fsync bool) (err error) {
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(f.Name())
+		}
+	}()
+
+	if _, err = f.Write(contents); err != nil {
+		return err
+	}
+	if err = f.Chmod(mode); err != nil {
+		return err
+	}
+	if fsync {
+		if err = f.Sync(); err != nil {
+			return err
+		}
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	if fsync {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// syncDir makes the rename of a file in dir durable
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		// Directories cannot be opened for syncing on Windows
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
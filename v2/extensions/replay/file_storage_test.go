@@ -0,0 +1,169 @@
+package replay
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileStoragePersists(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts []FileStorageOption
+	}{
+		{"always", nil},
+		{"never", []FileStorageOption{WithSyncPolicy(SyncNever)}},
+		{"interval", []FileStorageOption{WithSyncInterval(time.Hour)}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "replay.json")
+			s, err := NewFileStorage(path, tc.opts...)
+			if err != nil {
+				t.Fatalf("unexpected error (%v)", err)
+			}
+			s.Set("/foo/bar", 1234)
+			s.Set("/foo/baz", 5678)
+			s.Delete("/foo/baz")
+			s.Delete("/not/stored")
+			if err := s.Close(); err != nil {
+				t.Fatalf("unexpected error closing storage (%v)", err)
+			}
+
+			reopened, err := NewFileStorage(path)
+			if err != nil {
+				t.Fatalf("unexpected error (%v)", err)
+			}
+			defer reopened.Close()
+			got := reopened.AsMap()
+			if len(got) != 1 || got["/foo/bar"] != 1234 {
+				t.Errorf("expected only /foo/bar to be stored but got %v", got)
+			}
+		})
+	}
+}
+
+func TestFileStorageWritesOnEveryChange(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay.json")
+	s, err := NewFileStorage(path, WithSyncPolicy(SyncNever))
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	defer s.Close()
+
+	s.Set("/foo/bar", 1)
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected the file to be written (%v)", err)
+	}
+	if string(contents) != `{"/foo/bar":1}` {
+		t.Errorf("unexpected contents %s", contents)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected temporary files to be cleaned up but found %d files", len(entries))
+	}
+}
+
+func TestFileStorageSyncInterval(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay.json")
+	s, err := NewFileStorage(path, WithSyncInterval(10*time.Millisecond))
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	defer s.Close()
+
+	s.Set("/foo/bar", 1)
+	if _, err := os.Stat(path); err == nil {
+		t.Fatal("expected the write to wait for the interval")
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if contents, err := os.ReadFile(path); err == nil && string(contents) == `{"/foo/bar":1}` {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("replay IDs were never written")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Nothing is written again until something changes
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if _, err := os.Stat(path); err == nil {
+		t.Fatal("expected unchanged replay IDs not to be written again")
+	}
+}
+
+func TestFileStorageRejectsCorruptFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay.json")
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	if _, err := NewFileStorage(path); err == nil {
+		t.Error("expected an error loading a corrupt file")
+	}
+}
+
+func TestFileStorageReportsWriteErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "replay.json")
+	var reported error
+	s, err := NewFileStorage(path, WithErrorHandler(func(err error) { reported = err }))
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	s.Set("/foo/bar", 1)
+	if reported == nil {
+		t.Error("expected the write error to be reported")
+	}
+	if got, ok := s.Get("/foo/bar"); !ok || got != 1 {
+		t.Errorf("expected the replay ID to be kept in memory but got %d", got)
+	}
+}
+
+func TestFileStorageConcurrentSet(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "replay.json")
+	s, err := NewFileStorage(path, WithSyncPolicy(SyncNever))
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				s.Set(fmt.Sprintf("/foo/%d", i), int64(j))
+			}
+		}(i)
+	}
+	wg.Wait()
+	if err := s.Close(); err != nil {
+		t.Fatalf("unexpected error closing storage (%v)", err)
+	}
+
+	reopened, err := NewFileStorage(path)
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	got := reopened.AsMap()
+	for i := 0; i < 10; i++ {
+		if got[fmt.Sprintf("/foo/%d", i)] != 9 {
+			t.Errorf("expected /foo/%d to be 9 but got %v", i, got)
+		}
+	}
+}
@@ -2,17 +2,29 @@
 // replay ID extension for the Bayeux protocol.
 //
 // This provides an in-memory storage based off a map which is thread-safe.
-// You can initialize it with `NewMapStorage()`. Otherwise, you can implement
-// `IDStore`.
+// You can initialize it with `NewMapStorage()`. Replay IDs can be kept across
+// restarts in a file with `NewFileStorage(path)` or in an embedded database
+// with the boltstore package. Otherwise, you can implement `IDStore`.
+//
+// Channels without a stored replay ID are subscribed to from the position
+// given by `WithInitialPosition` or `WithDefaultPosition`, and
+// `WithFallbackPosition` resubscribes from another position when the server
+// rejects a replay ID.
 //
 // Example Usage:
 //
 //	client := gobayeux.NewClient(serverAddress)
 //	client.UseExtension(replay.New(replay.NewMapStorage()))
+//
+//	client.UseExtension(replay.New(
+//		store,
+//		replay.WithInitialPosition("/event/Order__e", replay.AllEvents),
+//		replay.WithFallbackPosition(replay.NewEvents),
+//	))
 package replay
 
 import (
-	"encoding/json"
+	"strings"
 	"sync"
 	"sync/atomic"
 
@@ -22,33 +34,120 @@ import (
 const (
 	// ExtensionName is the name used by Salesforce for its Bayeux extensions
 	ExtensionName string = "replay"
-	eventKey      string = "event"
-	replayIDKey   string = "replayId"
 
 	unsupported int32 = iota
 	supported
 )
 
+const (
+	// NewEvents is the replay ID that subscribes to only the events
+	// published after subscribing
+	NewEvents int64 = -1
+	// AllEvents is the replay ID that subscribes to every event the server
+	// still retains, followed by new events
+	AllEvents int64 = -2
+)
+
+// Options stores the configuration for an Extension. A position of zero means
+// none was chosen, as the server never assigns it as a replay ID.
+type Options struct {
+	// InitialPositions maps channels to the replay ID to subscribe from
+	// when the store holds none for them
+	InitialPositions map[string]int64
+	// DefaultPosition is the replay ID to subscribe from for channels in
+	// neither the store nor InitialPositions. When zero, the server
+	// chooses, which is usually NewEvents.
+	DefaultPosition int64
+	// FallbackPosition is the replay ID to resubscribe from when the
+	// server rejects the one sent, for example because it is too old to
+	// be retained. When zero, the subscription fails instead. It is used
+	// until the next event is received on the channel and is never
+	// written to the store, so the stored replay ID is tried again after
+	// a restart.
+	FallbackPosition int64
+	// Extractor finds the replay ID of each message received. When nil,
+	// DefaultExtractor is used.
+	Extractor IDExtractor
+}
+
+// Option defines the type passed into New for configuration
+type Option func(*Options)
+
+// WithInitialPosition returns an Option which subscribes to channel from
+// position when the store holds no replay ID for it
+func WithInitialPosition(channel string, position int64) Option {
+	return func(o *Options) {
+		if o.InitialPositions == nil {
+			o.InitialPositions = make(map[string]int64)
+		}
+		o.InitialPositions[channel] = position
+	}
+}
+
+// WithDefaultPosition returns an Option with position as the DefaultPosition
+func WithDefaultPosition(position int64) Option {
+	return func(o *Options) {
+		o.DefaultPosition = position
+	}
+}
+
+// WithFallbackPosition returns an Option with position as the
+// FallbackPosition
+func WithFallbackPosition(position int64) Option {
+	return func(o *Options) {
+		o.FallbackPosition = position
+	}
+}
+
+// WithExtractor returns an Option with extractor
+func WithExtractor(extractor IDExtractor) Option {
+	return func(o *Options) {
+		o.Extractor = extractor
+	}
+}
+
 // Extension represents the structure of the Salesforce Bayeux
 // Message Extension and manages the state
 type Extension struct {
 	supportedByServer *int32
 	replayStore       IDStore
+	options           Options
+	client            *bayeux.BayeuxClient
+
+	lock sync.Mutex
+	// sent holds the replay ID last sent when subscribing to each channel
+	sent map[string]int64
+	// fallbacks holds the FallbackPosition of channels whose replay ID
+	// the server rejected
+	fallbacks map[string]int64
 }
 
 // IDStore stores and manages the channels and replay IDs for a bayeux
 // server that supports the replay extension
 type IDStore interface {
-	Set(channel string, replayID int)
-	Get(channel string) (int, bool)
+	Set(channel string, replayID int64)
+	Get(channel string) (int64, bool)
 	Delete(channel string)
-	AsMap() map[string]int
+	AsMap() map[string]int64
 }
 
 // New creates a new extension instance
-func New(store IDStore) *Extension {
+func New(store IDStore, opts ...Option) *Extension {
+	var options Options
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if options.Extractor == nil {
+		options.Extractor = DefaultExtractor
+	}
 	defaultVal := unsupported
-	return &Extension{supportedByServer: &defaultVal, replayStore: store}
+	return &Extension{
+		supportedByServer: &defaultVal,
+		replayStore:       store,
+		options:           options,
+		sent:              make(map[string]int64),
+		fallbacks:         make(map[string]int64),
+	}
 }
 
 // Outgoing attaches any additional metadata to a message
@@ -59,8 +158,23 @@ func (e *Extension) Outgoing(ms *bayeux.Message) {
 		ext[ExtensionName] = true
 	case bayeux.MetaSubscribe:
 		if e.isSupported() {
+			replay := e.replayStore.AsMap()
+			channel := string(ms.Subscription)
+			if _, ok := replay[channel]; !ok && channel != "" {
+				if position, ok := e.initialPosition(channel); ok {
+					replay[channel] = position
+				}
+			}
+			e.lock.Lock()
+			for ch, position := range e.fallbacks {
+				replay[ch] = position
+			}
+			if replayID, ok := replay[channel]; ok {
+				e.sent[channel] = replayID
+			}
+			e.lock.Unlock()
 			ext := ms.GetExt(true)
-			ext[ExtensionName] = e.replayStore.AsMap()
+			ext[ExtensionName] = replay
 		}
 	}
 }
@@ -80,11 +194,17 @@ func (e *Extension) Incoming(ms *bayeux.Message) {
 			}
 			return
 		case bayeux.MetaUnsubscribe:
-			for _, channel := range ms.Subscription {
-				e.replayStore.Delete(string(channel))
+			e.replayStore.Delete(string(ms.Subscription))
+			e.lock.Lock()
+			delete(e.fallbacks, string(ms.Subscription))
+			e.lock.Unlock()
+			return
+		case bayeux.MetaSubscribe:
+			if !ms.Successful && e.isInvalidReplayID(ms) {
+				e.fallback(ms)
 			}
 			return
-		case bayeux.MetaConnect, bayeux.MetaSubscribe:
+		case bayeux.MetaConnect:
 			return
 		}
 	case bayeux.BroadcastChannel:
@@ -94,43 +214,75 @@ func (e *Extension) Incoming(ms *bayeux.Message) {
 	}
 }
 
+// ExtensionName implements the gobayeux.NamedExtension interface
+func (e *Extension) ExtensionName() string {
+	return ExtensionName
+}
+
 // Registered is called after an extension has been successfully registered
 func (e *Extension) Registered(extensionName string, client *bayeux.BayeuxClient) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.client = client
 }
 
 // Unregistered is called when an extension is unregistered
 func (e *Extension) Unregistered() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.client = nil
 }
 
-func (e *Extension) updateReplayID(ms *bayeux.Message) {
-	data := make(map[string]interface{})
-
-	var md *MessageData
-	if err := json.Unmarshal(ms.Data, &md); err != nil {
-		return
-	}
-
-	if err := json.Unmarshal([]byte(md.Data), &data); err != nil {
-		return
+// initialPosition returns the replay ID to subscribe to channel from when the
+// store holds none
+func (e *Extension) initialPosition(channel string) (int64, bool) {
+	if position, ok := e.options.InitialPositions[channel]; ok {
+		return position, true
 	}
-	event, ok := data[eventKey]
-	if !ok {
-		return
+	if e.options.DefaultPosition != 0 {
+		return e.options.DefaultPosition, true
 	}
-	eventMap, ok := event.(map[string]interface{})
-	if !ok {
-		return
+	return 0, false
+}
+
+// isInvalidReplayID reports whether ms rejects a subscription because of the
+// replay ID sent with it, e.g.
+//
+//	400::The replayId {1234} you provided was invalid.
+func (e *Extension) isInvalidReplayID(ms *bayeux.Message) bool {
+	msgErr, err := ms.ParseError()
+	if err != nil {
+		return false
 	}
-	replayIDVal, ok := eventMap[replayIDKey]
-	if !ok {
+	return msgErr.ErrorCode == 400 && strings.Contains(strings.ToLower(msgErr.ErrorMessage), "replayid")
+}
+
+// fallback asks the client to resubscribe to ms.Subscription from the
+// FallbackPosition after the server rejected the replay ID sent for it
+func (e *Extension) fallback(ms *bayeux.Message) {
+	channel := string(ms.Subscription)
+	position := e.options.FallbackPosition
+	e.lock.Lock()
+	client := e.client
+	sent, ok := e.sent[channel]
+	// Give up when the fallback position itself was rejected
+	if position == 0 || client == nil || (ok && sent == position) {
+		e.lock.Unlock()
 		return
 	}
+	e.fallbacks[channel] = position
+	e.lock.Unlock()
 
-	replayID, ok := replayIDVal.(float64)
-	if !ok {
-		return
+	client.RetrySubscription(ms.Subscription)
+}
+
+func (e *Extension) updateReplayID(ms *bayeux.Message) {
+	if replayID, ok := e.options.Extractor(ms); ok {
+		e.replayStore.Set(string(ms.Channel), replayID)
+		e.lock.Lock()
+		delete(e.fallbacks, string(ms.Channel))
+		e.lock.Unlock()
 	}
-	e.replayStore.Set(string(ms.Channel), int(replayID))
 }
 
 func (e *Extension) isSupported() bool {
@@ -153,24 +305,24 @@ type MessageData struct {
 // MapStorage implements the IDStore interface over a regular map with a
 // RWMutex protecting the access
 type MapStorage struct {
-	store map[string]int
+	store map[string]int64
 	lock  sync.RWMutex
 }
 
 // NewMapStorage creates a new MapStorage instance
 func NewMapStorage() *MapStorage {
-	return &MapStorage{store: make(map[string]int)}
+	return &MapStorage{store: make(map[string]int64)}
 }
 
 // Set implements the IDStore interface
-func (s *MapStorage) Set(channel string, replayID int) {
+func (s *MapStorage) Set(channel string, replayID int64) {
 	s.lock.Lock()
 	defer s.lock.Unlock()
 	s.store[channel] = replayID
 }
 
 // Get implements the IDStore interface
-func (s *MapStorage) Get(channel string) (replayID int, ok bool) {
+func (s *MapStorage) Get(channel string) (replayID int64, ok bool) {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
 
@@ -186,10 +338,10 @@ func (s *MapStorage) Delete(channel string) {
 }
 
 // AsMap implements the IDStore interface
-func (s *MapStorage) AsMap() map[string]int {
+func (s *MapStorage) AsMap() map[string]int64 {
 	s.lock.RLock()
 	defer s.lock.RUnlock()
-	replay := make(map[string]int)
+	replay := make(map[string]int64)
 	for k, v := range s.store {
 		replay[k] = v
 	}
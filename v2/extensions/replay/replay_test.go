@@ -1,10 +1,13 @@
 package replay
 
 import (
+	"context"
 	"encoding/json"
 	"testing"
+	"time"
 
 	bayeux "github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/internal/gobayeuxtest"
 )
 
 func TestNewInitializesOurState(t *testing.T) {
@@ -37,11 +40,11 @@ func TestOutgoingMetaHandshake(t *testing.T) {
 }
 
 func TestSupportedOutgoingMetaSubscribe(t *testing.T) {
-	want := 1234
+	want := int64(1234)
 	e := New(NewMapStorage())
 	*e.supportedByServer = supported
 	e.Registered(ExtensionName, nil)
-	e.replayStore = &MapStorage{store: map[string]int{"/foo/bar": want}}
+	e.replayStore = &MapStorage{store: map[string]int64{"/foo/bar": want}}
 	m := bayeux.Message{Channel: bayeux.MetaSubscribe}
 	e.Outgoing(&m)
 
@@ -50,7 +53,7 @@ func TestSupportedOutgoingMetaSubscribe(t *testing.T) {
 		t.Fatal("replay extension was not included in the subscribe")
 	}
 
-	value, ok := v.(map[string]int)
+	value, ok := v.(map[string]int64)
 	if !ok {
 		t.Fatal("replay extension value couldn't coerce to a map")
 	}
@@ -62,10 +65,142 @@ func TestSupportedOutgoingMetaSubscribe(t *testing.T) {
 	}
 }
 
+func TestOutgoingMetaSubscribeInitialPositions(t *testing.T) {
+	testCases := []struct {
+		name     string
+		opts     []Option
+		channel  bayeux.Channel
+		expected map[string]int64
+	}{
+		{"stored", []Option{WithInitialPosition("/foo/bar", AllEvents)}, "/foo/bar", map[string]int64{"/foo/bar": 1234}},
+		{"initial", []Option{WithInitialPosition("/foo/baz", AllEvents)}, "/foo/baz", map[string]int64{"/foo/bar": 1234, "/foo/baz": AllEvents}},
+		{"initial over default", []Option{WithInitialPosition("/foo/baz", AllEvents), WithDefaultPosition(NewEvents)}, "/foo/baz", map[string]int64{"/foo/bar": 1234, "/foo/baz": AllEvents}},
+		{"default", []Option{WithInitialPosition("/foo/baz", AllEvents), WithDefaultPosition(NewEvents)}, "/foo/qux", map[string]int64{"/foo/bar": 1234, "/foo/qux": NewEvents}},
+		{"left to the server", []Option{WithInitialPosition("/foo/baz", AllEvents)}, "/foo/qux", map[string]int64{"/foo/bar": 1234}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewMapStorage()
+			store.Set("/foo/bar", 1234)
+			e := New(store, tc.opts...)
+			*e.supportedByServer = supported
+			m := bayeux.Message{Channel: bayeux.MetaSubscribe, Subscription: tc.channel}
+			e.Outgoing(&m)
+
+			got, ok := m.Ext[ExtensionName].(map[string]int64)
+			if !ok {
+				t.Fatal("replay extension value couldn't coerce to a map")
+			}
+			if len(got) != len(tc.expected) {
+				t.Fatalf("expected %v but got %v", tc.expected, got)
+			}
+			for channel, replayID := range tc.expected {
+				if got[channel] != replayID {
+					t.Errorf("expected %v but got %v", tc.expected, got)
+				}
+			}
+			if _, ok := store.Get(string(tc.channel)); ok && tc.channel != "/foo/bar" {
+				t.Error("initial positions should not be stored")
+			}
+		})
+	}
+}
+
+func TestFallbackWhenReplayIDRejected(t *testing.T) {
+	testCases := []struct {
+		name       string
+		opts       []Option
+		successful bool
+		position   int64
+		requests   int
+	}{
+		{"fallback", []Option{WithFallbackPosition(AllEvents)}, true, AllEvents, 2},
+		{"no fallback", nil, false, 0, 1},
+		{"fallback rejected", []Option{WithFallbackPosition(50)}, false, 0, 2},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			server := gobayeuxtest.NewServer(t, gobayeuxtest.WithReplay(100))
+			if err := server.Start(context.Background()); err != nil {
+				t.Fatalf("failed to start test server (%v)", err)
+			}
+			client, err := bayeux.NewClient("https://example.com", bayeux.WithHTTPTransport(server))
+			if err != nil {
+				t.Fatalf("failed to create client (%v)", err)
+			}
+			store := NewMapStorage()
+			store.Set("/foo/bar", 42)
+			if err := client.UseExtension(New(store, tc.opts...)); err != nil {
+				t.Fatalf("unexpected error (%v)", err)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			client.Start(ctx)
+			defer func() { _ = client.Disconnect(context.Background()) }()
+			result, err := client.SubscribeAndWait(ctx, "/foo/bar", make(chan []bayeux.Message, 1))
+			if tc.successful != (err == nil) || result.Successful != tc.successful {
+				t.Fatalf("expected successful to be %t but got %+v (%v)", tc.successful, result, err)
+			}
+			if got := server.Requests(bayeux.MetaSubscribe); got != tc.requests {
+				t.Errorf("expected %d subscribe requests but got %d", tc.requests, got)
+			}
+			// The fallback position is never stored
+			if got, _ := store.Get("/foo/bar"); got != 42 {
+				t.Errorf("expected the store to hold 42 but got %d", got)
+			}
+			if !tc.successful {
+				return
+			}
+
+			if got, _ := server.ReplayPosition("/foo/bar"); got != tc.position {
+				t.Errorf("expected to subscribe from %d but got %d", tc.position, got)
+			}
+		})
+	}
+}
+
+func TestFallbackPositionLastsUntilAnEventIsReceived(t *testing.T) {
+	store := NewMapStorage()
+	store.Set("/foo/bar", 42)
+	client, err := bayeux.NewBayeuxClient(nil, nil, "https://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	e := New(store, WithFallbackPosition(AllEvents))
+	*e.supportedByServer = supported
+	e.Registered(ExtensionName, client)
+
+	replayIDSent := func() int64 {
+		m := bayeux.Message{Channel: bayeux.MetaSubscribe, Subscription: "/foo/bar"}
+		e.Outgoing(&m)
+		return m.Ext[ExtensionName].(map[string]int64)["/foo/bar"]
+	}
+
+	replayIDSent()
+	e.Incoming(&bayeux.Message{
+		Channel:      bayeux.MetaSubscribe,
+		Subscription: "/foo/bar",
+		Error:        "400::The replayId {42} you provided was invalid.",
+	})
+	if got := replayIDSent(); got != AllEvents {
+		t.Fatalf("expected to resubscribe from %d but got %d", AllEvents, got)
+	}
+
+	e.Incoming(&bayeux.Message{Channel: "/foo/bar", Data: json.RawMessage(`{"event":{"replayId":101}}`)})
+	if got := replayIDSent(); got != 101 {
+		t.Errorf("expected to resubscribe from 101 but got %d", got)
+	}
+}
+
 func TestUnsupportedOutgoingMetaSubscribe(t *testing.T) {
 	e := New(NewMapStorage())
 	e.Registered(ExtensionName, nil)
-	e.replayStore = &MapStorage{store: map[string]int{"/foo/bar": 1}}
+	e.replayStore = &MapStorage{store: map[string]int64{"/foo/bar": 1}}
 	m := bayeux.Message{Channel: bayeux.MetaSubscribe}
 	e.Outgoing(&m)
 
@@ -92,19 +227,22 @@ func TestDetectsItIsSupported(t *testing.T) {
 
 func TestIncomingMetaUnsubscribeRemovesChannel(t *testing.T) {
 	e := New(NewMapStorage())
-	e.replayStore = &MapStorage{store: map[string]int{
+	e.replayStore = &MapStorage{store: map[string]int64{
 		"/foo/bar": 1,
 		"/bar/*":   2,
 		"/":        3,
 	}}
 	m := bayeux.Message{
 		Channel:      bayeux.MetaUnsubscribe,
-		Subscription: bayeux.Channel("/"),
+		Subscription: bayeux.Channel("/foo/bar"),
 	}
 	e.Incoming(&m)
 
-	if _, ok := e.replayStore.Get("/"); ok {
-		t.Fatal("expected '/' to be removed from replay map but wasn't")
+	if _, ok := e.replayStore.Get("/foo/bar"); ok {
+		t.Fatal("expected '/foo/bar' to be removed from replay map but wasn't")
+	}
+	if _, ok := e.replayStore.Get("/"); !ok {
+		t.Fatal("expected '/' to be kept in replay map but wasn't")
 	}
 }
 
@@ -133,7 +271,7 @@ func TestIncomingUpdatesReplayIDStore(t *testing.T) {
 	testCases := []struct {
 		name string
 		data string
-		want int
+		want int64
 	}{
 		{
 			name: "valid data updates the id in the store",
@@ -176,7 +314,7 @@ func TestIncomingUpdatesReplayIDStore(t *testing.T) {
 			b, _ := json.Marshal(md)
 
 			e := New(NewMapStorage())
-			e.replayStore = &MapStorage{store: map[string]int{"/foo/bar": 1}}
+			e.replayStore = &MapStorage{store: map[string]int64{"/foo/bar": 1}}
 			m := bayeux.Message{
 				Channel: "/foo/bar",
 				Data:    b,
@@ -209,7 +347,7 @@ func TestUnregistered(t *testing.T) {
 
 func TestMapStorageSet(t *testing.T) {
 	s := NewMapStorage()
-	want := 1
+	want := int64(1)
 	s.Set("/foo/bar", want)
 	if got, ok := s.Get("/foo/bar"); !ok || want != got {
 		if !ok {
@@ -227,15 +365,15 @@ func TestEmptyMapStorageGet(t *testing.T) {
 }
 
 func TestMapStorageGet(t *testing.T) {
-	want := 1
-	s := &MapStorage{store: map[string]int{"/foo/bar": want}}
+	want := int64(1)
+	s := &MapStorage{store: map[string]int64{"/foo/bar": want}}
 	if got, ok := s.Get("/foo/bar"); !ok || want != got {
 		t.Fatalf("expected s.Get(\"/foo/bar\") = %d; got %d", want, got)
 	}
 }
 
 func TestMapStorageDelete(t *testing.T) {
-	s := &MapStorage{store: map[string]int{"/foo/bar": 1}}
+	s := &MapStorage{store: map[string]int64{"/foo/bar": 1}}
 	s.Delete("/foo/bar")
 	if _, ok := s.Get("/foo/bar"); ok {
 		t.Fatal("expected s.Get(\"/foo/bar\") to not return ok")
@@ -243,7 +381,7 @@ func TestMapStorageDelete(t *testing.T) {
 }
 
 func TestMapStorageAsMap(t *testing.T) {
-	s := &MapStorage{store: map[string]int{"/foo/bar": 1234}}
+	s := &MapStorage{store: map[string]int64{"/foo/bar": 1234}}
 	m := s.AsMap()
 	if len(m) != 1 {
 		t.Fatalf("expected len(m) = %d, got %d", 1, len(m))
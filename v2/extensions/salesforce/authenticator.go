@@ -0,0 +1,200 @@
+package salesforce
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+)
+
+const (
+	// DefaultTokenLifetime is how long a token is assumed to be valid when
+	// Salesforce does not say. It matches the default session timeout of
+	// an org.
+	DefaultTokenLifetime = 2 * time.Hour
+	// DefaultRefreshBefore is how long before it expires that a token is
+	// renewed
+	DefaultRefreshBefore = 5 * time.Minute
+)
+
+// Authenticator adds an access token obtained from Source to your requests.
+// The token is cached and renewed shortly before it expires, after
+// Salesforce rejects it with a 401 response, and when a /meta/handshake or
+// /meta/connect fails with `401::Authentication invalid`.
+//
+// When it is passed to gobayeux.WithHTTPTransport, the Client handshakes
+// again after Salesforce rejects the token since the session belongs to the
+// old one. Renewing the token before it expires keeps the session, along with
+// its cookies.
+//
+//	auth := salesforce.NewAuthenticator(&salesforce.JWTBearerFlow{...}, http.DefaultTransport)
+//	client, err := gobayeux.NewClient(serverAddress, gobayeux.WithHTTPTransport(auth))
+type Authenticator struct {
+	// Source issues new tokens
+	Source TokenSource
+	// Transport is any http transport that satisfies the http.RoundTripper
+	// interface
+	Transport http.RoundTripper
+	// TokenLifetime is how long tokens are valid when Salesforce does not
+	// say, which should match your org's session timeout. It defaults to
+	// DefaultTokenLifetime.
+	TokenLifetime time.Duration
+	// RefreshBefore is how long before it expires that a token is
+	// renewed. It defaults to DefaultRefreshBefore.
+	RefreshBefore time.Duration
+
+	mu      sync.Mutex
+	token   *Token
+	expiry  time.Time
+	cookies []*http.Cookie
+	now     func() time.Time
+}
+
+var _ bayeux.Reauthenticator = (*Authenticator)(nil)
+
+// NewAuthenticator creates an Authenticator which sends requests through
+// transport with tokens from source
+func NewAuthenticator(source TokenSource, transport http.RoundTripper) *Authenticator {
+	return &Authenticator{Source: source, Transport: transport}
+}
+
+// Token returns the cached token, first obtaining a new one from Source if
+// there is none or it is about to expire
+func (a *Authenticator) Token(ctx context.Context) (*Token, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token != nil && a.clock().Before(a.expiry.Add(-a.refreshBefore())) {
+		return a.token, nil
+	}
+	return a.refresh(ctx)
+}
+
+// RoundTrip implements the RoundTripper interface
+func (a *Authenticator) RoundTrip(request *http.Request) (*http.Response, error) {
+	if !strings.HasSuffix(request.URL.Hostname(), "salesforce.com") {
+		return a.Transport.RoundTrip(request)
+	}
+	if a.Source == nil {
+		return nil, errors.New("no Source provided to authenticator transport")
+	}
+
+	token, err := a.Token(request.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	newRequest := deepCopyRequestWitHeaders(request)
+	newRequest.Header.Set("Authorization", "Bearer "+token.AccessToken)
+
+	a.mu.Lock()
+	for _, cookie := range a.cookies {
+		newRequest.AddCookie(cookie)
+	}
+	a.mu.Unlock()
+
+	resp, err := a.Transport.RoundTrip(newRequest)
+	if err != nil {
+		return resp, err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.setCookies(resp.Cookies())
+	if resp.StatusCode == http.StatusUnauthorized && a.token == token {
+		// Make the next request obtain a new token
+		a.token = nil
+	}
+	return resp, nil
+}
+
+// Reauthenticate implements the gobayeux.Reauthenticator interface. It
+// obtains a new token when err or replies show that Salesforce rejected the
+// current one.
+func (a *Authenticator) Reauthenticate(ctx context.Context, replies []bayeux.Message, err error) bool {
+	if !isAuthenticationFailure(replies, err) {
+		return false
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if _, err = a.refresh(ctx); err != nil {
+		return false
+	}
+	// Cookies such as the session affinity belong to the old session
+	a.cookies = nil
+	return true
+}
+
+// setCookies keeps the cookies Salesforce set in a response for the
+// following requests, replacing those with the same name. It must be called
+// with a.mu held.
+func (a *Authenticator) setCookies(cookies []*http.Cookie) {
+	for _, cookie := range cookies {
+		a.cookies = slices.DeleteFunc(a.cookies, func(c *http.Cookie) bool {
+			return c.Name == cookie.Name
+		})
+		a.cookies = append(a.cookies, cookie)
+	}
+}
+
+// refresh must be called with a.mu held
+func (a *Authenticator) refresh(ctx context.Context) (*Token, error) {
+	if a.Source == nil {
+		return nil, errors.New("no Source provided to authenticator transport")
+	}
+	token, err := a.Source.Token(ctx)
+	if err != nil {
+		a.token = nil
+		return nil, err
+	}
+
+	expiry := token.Expiry
+	if expiry.IsZero() {
+		lifetime := a.TokenLifetime
+		if lifetime <= 0 {
+			lifetime = DefaultTokenLifetime
+		}
+		expiry = a.clock().Add(lifetime)
+	}
+	a.token = token
+	a.expiry = expiry
+	return token, nil
+}
+
+func (a *Authenticator) refreshBefore() time.Duration {
+	if a.RefreshBefore <= 0 {
+		return DefaultRefreshBefore
+	}
+	return a.RefreshBefore
+}
+
+func (a *Authenticator) clock() time.Time {
+	if a.now == nil {
+		return time.Now()
+	}
+	return a.now()
+}
+
+// isAuthenticationFailure reports whether a request failed because
+// Salesforce rejected our token, either with a 401 response or a reply such
+// as `401::Authentication invalid`
+func isAuthenticationFailure(replies []bayeux.Message, err error) bool {
+	var badResponse bayeux.BadResponseError
+	if errors.As(err, &badResponse) && badResponse.StatusCode == http.StatusUnauthorized {
+		return true
+	}
+	for _, m := range replies {
+		if m.Successful || m.Error == "" {
+			continue
+		}
+		if msgErr, err := m.ParseError(); err == nil && msgErr.ErrorCode == http.StatusUnauthorized {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,259 @@
+package salesforce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/internal/gobayeuxtest"
+)
+
+// countingTokenSource issues "token-1", "token-2" and so on
+type countingTokenSource struct {
+	mu     sync.Mutex
+	issued int
+	expiry time.Time
+}
+
+func (s *countingTokenSource) Token(context.Context) (*Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.issued++
+	return &Token{AccessToken: fmt.Sprintf("token-%d", s.issued), Expiry: s.expiry}, nil
+}
+
+func (s *countingTokenSource) Issued() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.issued
+}
+
+func TestAuthenticatorRefreshesBeforeExpiry(t *testing.T) {
+	now := time.Date(2020, 5, 1, 6, 0, 0, 0, time.UTC)
+	source := &countingTokenSource{}
+	a := NewAuthenticator(source, nil)
+	a.TokenLifetime = time.Hour
+	a.now = func() time.Time { return now }
+
+	testCases := []struct {
+		name     string
+		elapsed  time.Duration
+		expected string
+	}{
+		{"first request", 0, "token-1"},
+		{"cached", 30 * time.Minute, "token-1"},
+		{"about to expire", 26 * time.Minute, "token-2"},
+		{"cached again", 54 * time.Minute, "token-2"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		now = now.Add(tc.elapsed)
+		token, err := a.Token(context.Background())
+		if err != nil {
+			t.Fatalf("%s: unexpected error (%v)", tc.name, err)
+		}
+		if token.AccessToken != tc.expected {
+			t.Errorf("%s: expected %s but got %s", tc.name, tc.expected, token.AccessToken)
+		}
+	}
+
+	// Expiry reported by Salesforce takes precedence over TokenLifetime
+	source.expiry = now.Add(10 * time.Minute)
+	a.token = nil
+	if _, err := a.Token(context.Background()); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	now = now.Add(6 * time.Minute)
+	if token, _ := a.Token(context.Background()); token.AccessToken != "token-4" {
+		t.Errorf("expected the token to be renewed but got %s", token.AccessToken)
+	}
+}
+
+func TestAuthenticatorRoundTrip(t *testing.T) {
+	source := &countingTokenSource{}
+	trt := &TestRoundTripper{ExpectedToken: "token-1"}
+	a := NewAuthenticator(source, trt)
+
+	req, _ := http.NewRequest("POST", "https://example.my.salesforce.com/cometd/59.0", nil)
+	if _, err := a.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	if _, err := a.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	if trt.CallCount != 2 || source.Issued() != 1 {
+		t.Errorf("expected 2 requests with 1 token but made %d with %d", trt.CallCount, source.Issued())
+	}
+
+	req, _ = http.NewRequest("GET", "https://github.com", nil)
+	if _, err := a.RoundTrip(req); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	if source.Issued() != 1 {
+		t.Error("expected no token for other hosts")
+	}
+}
+
+// cookieRoundTripper sets a session cookie in its first response and records
+// the cookies sent with each request
+type cookieRoundTripper struct {
+	sent [][]*http.Cookie
+}
+
+func (t *cookieRoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	header := http.Header{}
+	if len(t.sent) == 0 {
+		header.Set("Set-Cookie", "sfdc-stream=affinity")
+	}
+	t.sent = append(t.sent, request.Cookies())
+	return &http.Response{StatusCode: http.StatusOK, Header: header}, nil
+}
+
+func TestAuthenticatorKeepsCookiesUntilReauthenticated(t *testing.T) {
+	now := time.Date(2020, 5, 1, 6, 0, 0, 0, time.UTC)
+	source := &countingTokenSource{}
+	crt := &cookieRoundTripper{}
+	a := NewAuthenticator(source, crt)
+	a.TokenLifetime = time.Hour
+	a.now = func() time.Time { return now }
+
+	roundTrip := func() {
+		t.Helper()
+		req, _ := http.NewRequest("POST", "https://example.my.salesforce.com/cometd/59.0", nil)
+		if _, err := a.RoundTrip(req); err != nil {
+			t.Fatalf("unexpected error (%v)", err)
+		}
+	}
+
+	roundTrip()
+	roundTrip()
+	// Renewing the token shortly before it expires keeps the session
+	now = now.Add(56 * time.Minute)
+	roundTrip()
+	if source.Issued() != 2 {
+		t.Fatalf("expected the token to be renewed before it expires but %d were issued", source.Issued())
+	}
+	// Salesforce rejecting the token ends the session
+	if !a.Reauthenticate(context.Background(), nil, bayeux.BadResponseError{StatusCode: http.StatusUnauthorized}) {
+		t.Fatal("expected to reauthenticate")
+	}
+	roundTrip()
+
+	expected := []int{0, 1, 1, 0}
+	for i, cookies := range crt.sent {
+		if len(cookies) != expected[i] {
+			t.Errorf("request %d: expected %d cookies but sent %v", i+1, expected[i], cookies)
+		}
+	}
+}
+
+func TestAuthenticatorReauthenticate(t *testing.T) {
+	testCases := []struct {
+		name     string
+		replies  []bayeux.Message
+		err      error
+		expected bool
+	}{
+		{
+			name:     "401 response",
+			err:      bayeux.ConnectionFailedError{Err: bayeux.BadResponseError{StatusCode: http.StatusUnauthorized}},
+			expected: true,
+		},
+		{
+			name:     "authentication invalid",
+			replies:  []bayeux.Message{{Channel: bayeux.MetaHandshake, Error: "401::Authentication invalid"}},
+			err:      errors.New("handshake was not successful: 401::Authentication invalid"),
+			expected: true,
+		},
+		{
+			name:    "server error",
+			err:     bayeux.ConnectionFailedError{Err: bayeux.BadResponseError{StatusCode: http.StatusBadGateway}},
+			replies: []bayeux.Message{{Channel: bayeux.MetaConnect, Successful: true}},
+		},
+		{
+			name:    "unknown client",
+			replies: []bayeux.Message{{Channel: bayeux.MetaConnect, Error: "403::Unknown client"}},
+			err:     bayeux.ConnectionFailedError{Err: bayeux.ErrFailedToConnect},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			source := &countingTokenSource{}
+			a := NewAuthenticator(source, nil)
+			if got := a.Reauthenticate(context.Background(), tc.replies, tc.err); got != tc.expected {
+				t.Fatalf("expected %t but got %t", tc.expected, got)
+			}
+			expectedIssued := 0
+			if tc.expected {
+				expectedIssued = 1
+			}
+			if source.Issued() != expectedIssued {
+				t.Errorf("expected %d tokens to be issued but got %d", expectedIssued, source.Issued())
+			}
+		})
+	}
+}
+
+func TestClientReauthenticatesAndHandshakes(t *testing.T) {
+	var mu sync.Mutex
+	accepted := "Bearer token-1"
+	server := gobayeuxtest.NewServer(t, gobayeuxtest.WithAuthorization(func(authorization string) bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return authorization == accepted
+	}))
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	source := &countingTokenSource{}
+	client, err := bayeux.NewClient(
+		"https://example.my.salesforce.com/cometd/59.0",
+		bayeux.WithHTTPTransport(NewAuthenticator(source, server)),
+	)
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	msgs := make(chan []bayeux.Message)
+	go func() {
+		for range msgs {
+		}
+	}()
+	if _, err := client.SubscribeAndWait(ctx, "/event/Foo__e", msgs); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	// Expire the first token on the server
+	mu.Lock()
+	accepted = "Bearer token-2"
+	mu.Unlock()
+
+	for server.Handshakes() < 2 || server.Requests(bayeux.MetaSubscribe) < 2 {
+		select {
+		case err := <-errs:
+			t.Fatalf("unexpected error (%v)", err)
+		case <-ctx.Done():
+			t.Fatalf("client did not handshake again; handshakes=%d tokens=%d connects=%d", server.Handshakes(), source.Issued(), server.Connects())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if source.Issued() != 2 {
+		t.Errorf("expected 2 tokens to be issued but got %d", source.Issued())
+	}
+
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatalf("unexpected error disconnecting (%v)", err)
+	}
+}
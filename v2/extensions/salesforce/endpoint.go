@@ -0,0 +1,209 @@
+package salesforce
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+)
+
+const (
+	// versionsPath lists the API versions an org supports and needs no
+	// authentication
+	versionsPath = "/services/data/"
+	// cometdPath is the Streaming API endpoint relative to the instance
+	// URL, followed by the API version
+	cometdPath = "/cometd/"
+	// identityPathPrefix starts the path of identity URLs, e.g.
+	// https://login.salesforce.com/id/00Dxx0000001gPL/005xx000001Sv6e
+	identityPathPrefix = "/id/"
+)
+
+// APIVersion is an API version an org supports as listed by the versions
+// resource
+//
+// See also: https://developer.salesforce.com/docs/atlas.en-us.api_rest.meta/api_rest/resources_versions.htm
+type APIVersion struct {
+	Label   string `json:"label"`
+	URL     string `json:"url"`
+	Version string `json:"version"`
+}
+
+// UnsupportedVersionError is returned when an org does not support the API
+// version asked for
+type UnsupportedVersionError struct {
+	Version   string
+	Supported []APIVersion
+}
+
+func (e UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("API version '%s' is not supported", e.Version)
+}
+
+// StreamingEndpoint builds the Streaming API endpoint for apiVersion on the
+// org at instanceURL, e.g.
+//
+//	StreamingEndpoint("https://example.my.salesforce.com", "59.0")
+//	// https://example.my.salesforce.com/cometd/59.0
+//
+// apiVersion may be written as "59.0", "v59.0" or "59".
+func StreamingEndpoint(instanceURL, apiVersion string) (string, error) {
+	instance, err := instanceBase(instanceURL)
+	if err != nil {
+		return "", err
+	}
+	version, err := normalizeVersion(apiVersion)
+	if err != nil {
+		return "", err
+	}
+	return instance + cometdPath + version, nil
+}
+
+// Versions returns the API versions supported by the org at instanceURL,
+// oldest first
+func Versions(ctx context.Context, client *http.Client, instanceURL string) ([]APIVersion, error) {
+	instance, err := instanceBase(instanceURL)
+	if err != nil {
+		return nil, err
+	}
+	var versions []APIVersion
+	if err := getJSON(ctx, client, instance+versionsPath, &versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// DiscoverStreamingEndpoint builds the Streaming API endpoint after checking
+// that the org supports apiVersion, or for the latest version the org
+// supports when apiVersion is empty.
+//
+// loginURL is either the instance URL or the identity URL returned with a
+// Token. The identity URL names the login server rather than the org, so it
+// is looked up to find the instance, which needs client to authenticate its
+// requests, e.g. with an Authenticator.
+func DiscoverStreamingEndpoint(ctx context.Context, client *http.Client, loginURL, apiVersion string) (string, error) {
+	instanceURL, err := resolveInstance(ctx, client, loginURL)
+	if err != nil {
+		return "", err
+	}
+	versions, err := Versions(ctx, client, instanceURL)
+	if err != nil {
+		return "", err
+	}
+
+	if apiVersion == "" {
+		latest, ok := latestVersion(versions)
+		if !ok {
+			return "", UnsupportedVersionError{"latest", versions}
+		}
+		return StreamingEndpoint(instanceURL, latest)
+	}
+
+	version, err := normalizeVersion(apiVersion)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return StreamingEndpoint(instanceURL, version)
+		}
+	}
+	return "", UnsupportedVersionError{version, versions}
+}
+
+// latestVersion returns the highest of versions
+func latestVersion(versions []APIVersion) (string, bool) {
+	latest, highest := "", 0.0
+	for _, v := range versions {
+		if n, err := strconv.ParseFloat(v.Version, 64); err == nil && n > highest {
+			latest, highest = v.Version, n
+		}
+	}
+	return latest, latest != ""
+}
+
+// identity holds the part of the identity resource naming the instance
+//
+// See also: https://help.salesforce.com/s/articleView?id=sf.remoteaccess_using_openid.htm
+type identity struct {
+	URLs struct {
+		REST string `json:"rest"`
+	} `json:"urls"`
+}
+
+// resolveInstance returns the instance URL for loginURL, looking up identity
+// URLs
+func resolveInstance(ctx context.Context, client *http.Client, loginURL string) (string, error) {
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(u.Path, identityPathPrefix) {
+		return loginURL, nil
+	}
+
+	var id identity
+	if err := getJSON(ctx, client, loginURL, &id); err != nil {
+		return "", err
+	}
+	if id.URLs.REST == "" {
+		return "", fmt.Errorf("identity '%s' does not include a REST URL", loginURL)
+	}
+	return id.URLs.REST, nil
+}
+
+// instanceBase returns the scheme and host of instanceURL, dropping any
+// path such as that of a REST URL
+func instanceBase(instanceURL string) (string, error) {
+	u, err := url.Parse(instanceURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return "", fmt.Errorf("instance URL '%s' must include a scheme and host", instanceURL)
+	}
+	return u.Scheme + "://" + u.Host, nil
+}
+
+// normalizeVersion converts "v59.0" or "59" to the "59.0" used in URLs
+func normalizeVersion(apiVersion string) (string, error) {
+	version := strings.TrimPrefix(strings.TrimPrefix(apiVersion, "v"), "V")
+	if !strings.Contains(version, ".") {
+		version += ".0"
+	}
+	if _, err := strconv.ParseFloat(version, 64); err != nil {
+		return "", fmt.Errorf("invalid API version '%s'", apiVersion)
+	}
+	return version, nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, u string, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return bayeux.BadResponseError{StatusCode: resp.StatusCode, Status: resp.Status, Body: body}
+	}
+	return json.Unmarshal(body, v)
+}
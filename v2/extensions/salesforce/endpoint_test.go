@@ -0,0 +1,114 @@
+package salesforce
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+)
+
+func TestStreamingEndpoint(t *testing.T) {
+	testCases := []struct {
+		name        string
+		instanceURL string
+		version     string
+		expected    string
+		shouldErr   bool
+	}{
+		{"instance URL", "https://example.my.salesforce.com", "59.0", "https://example.my.salesforce.com/cometd/59.0", false},
+		{"trailing slash", "https://example.my.salesforce.com/", "v59.0", "https://example.my.salesforce.com/cometd/59.0", false},
+		{"REST URL", "https://example.my.salesforce.com/services/data/v59.0/", "59", "https://example.my.salesforce.com/cometd/59.0", false},
+		{"port", "http://localhost:8080", "48.0", "http://localhost:8080/cometd/48.0", false},
+		{"no scheme", "example.my.salesforce.com", "59.0", "", true},
+		{"invalid version", "https://example.my.salesforce.com", "latest", "", true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := StreamingEndpoint(tc.instanceURL, tc.version)
+			if tc.shouldErr {
+				if err == nil {
+					t.Fatalf("expected an error but got %s", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error (%v)", err)
+			}
+			if got != tc.expected {
+				t.Errorf("expected %s but got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+// newInstanceServer starts a stand-in for an org which supports versions and
+// serves an identity resource pointing back at itself
+func newInstanceServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/services/data/", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`[
+			{"label": "Spring '20", "url": "/services/data/v48.0", "version": "48.0"},
+			{"label": "Winter '24", "url": "/services/data/v59.0", "version": "59.0"}
+		]`))
+	})
+	mux.HandleFunc("/id/00Dxx/005xx", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"urls": {"rest": "` + server.URL + `/services/data/v{version}/"}}`))
+	})
+	server = httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDiscoverStreamingEndpoint(t *testing.T) {
+	server := newInstanceServer(t)
+
+	testCases := []struct {
+		name     string
+		loginURL string
+		version  string
+		expected string
+	}{
+		{"instance URL", server.URL, "48.0", server.URL + "/cometd/48.0"},
+		{"identity URL", server.URL + "/id/00Dxx/005xx", "v59.0", server.URL + "/cometd/59.0"},
+		{"latest", server.URL, "", server.URL + "/cometd/59.0"},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := DiscoverStreamingEndpoint(context.Background(), server.Client(), tc.loginURL, tc.version)
+			if err != nil {
+				t.Fatalf("unexpected error (%v)", err)
+			}
+			if got != tc.expected {
+				t.Errorf("expected %s but got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestDiscoverStreamingEndpointErrors(t *testing.T) {
+	server := newInstanceServer(t)
+
+	_, err := DiscoverStreamingEndpoint(context.Background(), server.Client(), server.URL, "60.0")
+	var unsupported UnsupportedVersionError
+	if !errors.As(err, &unsupported) {
+		t.Fatalf("expected an UnsupportedVersionError but got %v", err)
+	}
+	if unsupported.Version != "60.0" || len(unsupported.Supported) != 2 {
+		t.Errorf("unexpected error %+v", unsupported)
+	}
+
+	_, err = DiscoverStreamingEndpoint(context.Background(), server.Client(), server.URL+"/id/00Dxx/missing", "59.0")
+	var badResponse bayeux.BadResponseError
+	if !errors.As(err, &badResponse) || badResponse.StatusCode != http.StatusNotFound {
+		t.Errorf("expected a 404 BadResponseError but got %v", err)
+	}
+}
@@ -0,0 +1,224 @@
+package salesforce
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+)
+
+// Recovery is the action recommended to recover from an error reported by
+// the Streaming API
+type Recovery int
+
+const (
+	// RecoveryNone means there is nothing to be done automatically
+	RecoveryNone Recovery = iota
+	// RecoveryReauthenticate means the access token was rejected and must
+	// be renewed before handshaking again, as an Authenticator does
+	RecoveryReauthenticate
+	// RecoveryRehandshake means the session expired and a new one must be
+	// established before subscribing again
+	RecoveryRehandshake
+	// RecoveryResetReplay means the stored replay ID must be replaced,
+	// e.g. with replay.NewEvents, before subscribing again. See also
+	// replay.WithFallbackPosition
+	RecoveryResetReplay
+	// RecoveryBackOff means no more requests should be made until the limit
+	// resets at midnight UTC
+	RecoveryBackOff
+)
+
+func (r Recovery) String() string {
+	switch r {
+	case RecoveryReauthenticate:
+		return "reauthenticate"
+	case RecoveryRehandshake:
+		return "rehandshake"
+	case RecoveryResetReplay:
+		return "reset replay"
+	case RecoveryBackOff:
+		return "back off"
+	default:
+		return "none"
+	}
+}
+
+// StreamingError is implemented by each error the Streaming API reports
+// which ParseError recognises
+type StreamingError interface {
+	error
+	// Recovery returns the action recommended to recover from the error
+	Recovery() Recovery
+}
+
+var (
+	_ StreamingError = AuthenticationError{}
+	_ StreamingError = UnknownClientError{}
+	_ StreamingError = InvalidReplayIDError{}
+	_ StreamingError = DailyLimitError{}
+)
+
+// AuthenticationError is reported as `401::Authentication invalid` when the
+// access token has expired or been revoked
+type AuthenticationError struct {
+	Channel      bayeux.Channel
+	MessageError bayeux.MessageError
+}
+
+func (e AuthenticationError) Error() string {
+	return fmt.Sprintf("authentication failed on %s: %s", e.Channel, e.MessageError.ErrorMessage)
+}
+
+// Recovery implements the StreamingError interface
+func (e AuthenticationError) Recovery() Recovery {
+	return RecoveryReauthenticate
+}
+
+// UnknownClientError is reported as `403::Unknown client` when the server
+// no longer knows our client ID, usually because the session timed out
+// after the client stopped polling for too long
+type UnknownClientError struct {
+	Channel      bayeux.Channel
+	MessageError bayeux.MessageError
+}
+
+func (e UnknownClientError) Error() string {
+	return fmt.Sprintf("unknown client on %s: %s", e.Channel, e.MessageError.ErrorMessage)
+}
+
+// Recovery implements the StreamingError interface
+func (e UnknownClientError) Recovery() Recovery {
+	return RecoveryRehandshake
+}
+
+// InvalidReplayIDError is reported as `400::The replayId {5} you provided was
+// invalid` when subscribing with a replay ID the event bus no longer
+// retains, e.g. because it is older than the retention window
+type InvalidReplayIDError struct {
+	Subscription bayeux.Channel
+	// ReplayID is the rejected replay ID, if the error included it
+	ReplayID     int64
+	MessageError bayeux.MessageError
+}
+
+func (e InvalidReplayIDError) Error() string {
+	return fmt.Sprintf("replay ID %d is invalid for %s: %s", e.ReplayID, e.Subscription, e.MessageError.ErrorMessage)
+}
+
+// Recovery implements the StreamingError interface
+func (e InvalidReplayIDError) Recovery() Recovery {
+	return RecoveryResetReplay
+}
+
+// DailyLimitError is reported, e.g. as `403::Organization total events daily
+// limit exceeded`, once the org has used up one of its daily Streaming API
+// allocations
+type DailyLimitError struct {
+	Channel      bayeux.Channel
+	MessageError bayeux.MessageError
+	// ResetAt is the next midnight UTC, when the limit is expected to
+	// have been reset
+	ResetAt time.Time
+}
+
+func (e DailyLimitError) Error() string {
+	return fmt.Sprintf("daily limit exceeded on %s until %s: %s", e.Channel, e.ResetAt.Format(time.RFC3339), e.MessageError.ErrorMessage)
+}
+
+// Recovery implements the StreamingError interface
+func (e DailyLimitError) Recovery() Recovery {
+	return RecoveryBackOff
+}
+
+// replayIDPattern finds the replay ID in the message of an
+// InvalidReplayIDError
+var replayIDPattern = regexp.MustCompile(`\{(-?\d+)\}`)
+
+// ParseError identifies the error in m's Error field, as parsed by
+// Message.ParseError. It returns nil when m has no error or it is not one
+// of the errors described by a StreamingError.
+func ParseError(m bayeux.Message) StreamingError {
+	return parseError(m, time.Now())
+}
+
+func parseError(m bayeux.Message, now time.Time) StreamingError {
+	if m.Successful || m.Error == "" {
+		return nil
+	}
+	msgErr, err := m.ParseError()
+	if err != nil {
+		return nil
+	}
+
+	message := strings.ToLower(msgErr.ErrorMessage)
+	switch {
+	case msgErr.ErrorCode == http.StatusUnauthorized:
+		return AuthenticationError{m.Channel, msgErr}
+	case strings.Contains(message, "unknown client"):
+		return UnknownClientError{m.Channel, msgErr}
+	case msgErr.ErrorCode == http.StatusBadRequest && strings.Contains(message, "replayid"):
+		e := InvalidReplayIDError{Subscription: m.Subscription, MessageError: msgErr}
+		if match := replayIDPattern.FindStringSubmatch(msgErr.ErrorMessage); match != nil {
+			e.ReplayID, _ = strconv.ParseInt(match[1], 10, 64)
+		}
+		return e
+	case strings.Contains(message, "daily") && strings.Contains(message, "limit"):
+		return DailyLimitError{m.Channel, msgErr, nextMidnightUTC(now)}
+	}
+	return nil
+}
+
+// ErrorFrom returns the first error in replies which ParseError recognises,
+// or nil if there is none
+func ErrorFrom(replies []bayeux.Message) StreamingError {
+	for _, m := range replies {
+		if err := ParseError(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecoveryFor returns the action recommended to recover from err, such as
+// one returned by the gobayeux Client. It looks for a StreamingError, or a
+// reply which ParseError recognises, wrapped by err, and returns
+// RecoveryNone when there is neither.
+func RecoveryFor(err error) Recovery {
+	if streamingErr := streamingErrorFrom(err); streamingErr != nil {
+		return streamingErr.Recovery()
+	}
+	// Salesforce may reject an expired token before replying at all
+	var badResponse bayeux.BadResponseError
+	if errors.As(err, &badResponse) && badResponse.StatusCode == http.StatusUnauthorized {
+		return RecoveryReauthenticate
+	}
+	return RecoveryNone
+}
+
+// streamingErrorFrom returns the StreamingError wrapped by err or parsed from
+// the reply which caused it, if any
+func streamingErrorFrom(err error) StreamingError {
+	var streamingErr StreamingError
+	if errors.As(err, &streamingErr) {
+		return streamingErr
+	}
+	var replyErr bayeux.UnsuccessfulReplyError
+	if errors.As(err, &replyErr) {
+		return ParseError(replyErr.Reply)
+	}
+	var unexpected bayeux.UnexpectedReplyError
+	if errors.As(err, &unexpected) {
+		return ParseError(unexpected.Reply)
+	}
+	return nil
+}
+
+func nextMidnightUTC(now time.Time) time.Time {
+	return now.UTC().Truncate(24 * time.Hour).Add(24 * time.Hour)
+}
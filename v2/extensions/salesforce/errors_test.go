@@ -0,0 +1,167 @@
+package salesforce
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/internal/gobayeuxtest"
+)
+
+func TestParseError(t *testing.T) {
+	now := time.Date(2020, 5, 1, 15, 30, 0, 0, time.FixedZone("PDT", -7*60*60))
+	midnight := time.Date(2020, 5, 2, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		message  bayeux.Message
+		expected StreamingError
+		recovery Recovery
+	}{
+		{
+			name:     "successful",
+			message:  bayeux.Message{Channel: bayeux.MetaConnect, Successful: true},
+			recovery: RecoveryNone,
+		},
+		{
+			name:     "unparsable",
+			message:  bayeux.Message{Channel: bayeux.MetaConnect, Error: "Unknown client"},
+			recovery: RecoveryNone,
+		},
+		{
+			name:     "unrecognised",
+			message:  bayeux.Message{Channel: bayeux.MetaSubscribe, Error: "403::Restricted channel"},
+			recovery: RecoveryNone,
+		},
+		{
+			name:    "authentication invalid",
+			message: bayeux.Message{Channel: bayeux.MetaHandshake, Error: "401::Authentication invalid"},
+			expected: AuthenticationError{
+				Channel:      bayeux.MetaHandshake,
+				MessageError: bayeux.MessageError{ErrorCode: 401, ErrorArgs: []string{""}, ErrorMessage: "Authentication invalid"},
+			},
+			recovery: RecoveryReauthenticate,
+		},
+		{
+			name:    "unknown client",
+			message: bayeux.Message{Channel: bayeux.MetaConnect, Error: "403::Unknown client"},
+			expected: UnknownClientError{
+				Channel:      bayeux.MetaConnect,
+				MessageError: bayeux.MessageError{ErrorCode: 403, ErrorArgs: []string{""}, ErrorMessage: "Unknown client"},
+			},
+			recovery: RecoveryRehandshake,
+		},
+		{
+			name: "invalid replay ID",
+			message: bayeux.Message{
+				Channel:      bayeux.MetaSubscribe,
+				Subscription: "/event/Foo__e",
+				Error:        "400::The replayId {12} you provided was invalid.  Please provide a valid ID, -2 to replay all events, or -1 to replay only new events.",
+			},
+			expected: InvalidReplayIDError{
+				Subscription: "/event/Foo__e",
+				ReplayID:     12,
+				MessageError: bayeux.MessageError{
+					ErrorCode:    400,
+					ErrorArgs:    []string{""},
+					ErrorMessage: "The replayId {12} you provided was invalid.  Please provide a valid ID, -2 to replay all events, or -1 to replay only new events.",
+				},
+			},
+			recovery: RecoveryResetReplay,
+		},
+		{
+			name:    "daily limit",
+			message: bayeux.Message{Channel: bayeux.MetaHandshake, Error: "403::Organization total events daily limit exceeded"},
+			expected: DailyLimitError{
+				Channel:      bayeux.MetaHandshake,
+				MessageError: bayeux.MessageError{ErrorCode: 403, ErrorArgs: []string{""}, ErrorMessage: "Organization total events daily limit exceeded"},
+				ResetAt:      midnight,
+			},
+			recovery: RecoveryBackOff,
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			got := parseError(tc.message, now)
+			if fmt.Sprintf("%#v", got) != fmt.Sprintf("%#v", tc.expected) {
+				t.Errorf("expected %#v but got %#v", tc.expected, got)
+			}
+			var err error
+			if got != nil {
+				err = bayeux.ConnectionFailedError{Err: got}
+			}
+			if recovery := RecoveryFor(err); recovery != tc.recovery {
+				t.Errorf("expected recovery %s but got %s", tc.recovery, recovery)
+			}
+		})
+	}
+}
+
+func TestRecoveryForClientErrors(t *testing.T) {
+	unknownClient := bayeux.Message{Channel: bayeux.MetaConnect, Error: "403::Unknown client"}
+
+	testCases := []struct {
+		name     string
+		err      error
+		recovery Recovery
+	}{
+		{"unsuccessful reply", bayeux.ConnectionFailedError{Err: bayeux.UnsuccessfulReplyError{Reply: unknownClient, Err: bayeux.ErrFailedToConnect}}, RecoveryRehandshake},
+		{"unexpected reply", bayeux.ConnectionFailedError{Err: bayeux.UnexpectedReplyError{Channel: bayeux.MetaConnect, ID: "7", Reply: unknownClient}}, RecoveryRehandshake},
+		{"unauthorized response", bayeux.HandshakeFailedError{Err: bayeux.BadResponseError{StatusCode: http.StatusUnauthorized}}, RecoveryReauthenticate},
+		{"unrecognised reply", bayeux.UnsuccessfulReplyError{Reply: bayeux.Message{Error: "403::Handshake denied"}, Err: errors.New("denied")}, RecoveryNone},
+		{"no reply", bayeux.ConnectionFailedError{Err: bayeux.ErrFailedToConnect}, RecoveryNone},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			if recovery := RecoveryFor(tc.err); recovery != tc.recovery {
+				t.Errorf("expected recovery %s but got %s", tc.recovery, recovery)
+			}
+		})
+	}
+}
+
+func TestRecoveryForErrorFromStart(t *testing.T) {
+	server := gobayeuxtest.NewServer(t,
+		gobayeuxtest.WithHandshakeAdvice(1, &bayeux.Advice{Reconnect: bayeux.ReconnectNone}),
+		gobayeuxtest.WithHandshakeRejection("401::Authentication invalid"),
+	)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+	client, err := bayeux.NewClient("https://example.my.salesforce.com/cometd/59.0", bayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	select {
+	case err := <-client.Start(ctx):
+		if recovery := RecoveryFor(err); recovery != RecoveryReauthenticate {
+			t.Errorf("expected recovery %s but got %s for %v", RecoveryReauthenticate, recovery, err)
+		}
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for the client to stop")
+	}
+}
+
+func TestErrorFrom(t *testing.T) {
+	replies := []bayeux.Message{
+		{Channel: "/event/Foo__e", Data: []byte(`{}`)},
+		{Channel: bayeux.MetaConnect, Error: "403::Unknown client"},
+	}
+	if _, ok := ErrorFrom(replies).(UnknownClientError); !ok {
+		t.Errorf("expected an UnknownClientError but got %v", ErrorFrom(replies))
+	}
+	if err := ErrorFrom(replies[:1]); err != nil {
+		t.Errorf("expected no error but got %v", err)
+	}
+}
@@ -0,0 +1,282 @@
+package salesforce
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultLoginURL is where tokens are requested from unless a flow is
+	// given another LoginURL, such as https://test.salesforce.com for
+	// sandboxes or your My Domain URL
+	DefaultLoginURL = "https://login.salesforce.com"
+	// tokenPath is the OAuth 2.0 token endpoint relative to the login URL
+	tokenPath = "/services/oauth2/token"
+	// jwtLifetime is how long a JWT bearer assertion is valid for.
+	// Salesforce rejects assertions which expire more than 3 minutes out.
+	jwtLifetime = 3 * time.Minute
+)
+
+// ErrNoPrivateKey is returned by JWTBearerFlow when it has no key to sign its
+// assertion with
+var ErrNoPrivateKey = errors.New("no PrivateKey provided to JWT bearer flow")
+
+// Token is an OAuth 2.0 access token issued by Salesforce
+type Token struct {
+	// AccessToken is sent as the bearer token with each request
+	AccessToken string
+	// TokenType is the type of AccessToken, which is always "Bearer"
+	TokenType string
+	// InstanceURL is the base URL of the org the token is for, e.g.
+	// https://example.my.salesforce.com
+	InstanceURL string
+	// ID is the identity URL of the user the token was issued to
+	ID string
+	// IssuedAt is when the token was issued
+	IssuedAt time.Time
+	// Expiry is when the token expires. It is zero when Salesforce did not
+	// say, as the lifetime of a token depends on the org's session
+	// settings.
+	Expiry time.Time
+}
+
+// TokenSource obtains new access tokens, usually through one of the OAuth 2.0
+// flows Salesforce supports
+type TokenSource interface {
+	Token(ctx context.Context) (*Token, error)
+}
+
+// OAuthError is returned when Salesforce refuses to issue a token
+//
+// See also: https://help.salesforce.com/s/articleView?id=sf.remoteaccess_oauth_flow_errors.htm
+type OAuthError struct {
+	StatusCode  int
+	ErrorCode   string
+	Description string
+}
+
+func (e OAuthError) Error() string {
+	return fmt.Sprintf("token request failed with %d: %s: %s", e.StatusCode, e.ErrorCode, e.Description)
+}
+
+// JWTBearerFlow obtains tokens with the OAuth 2.0 JWT bearer flow, signing
+// an assertion with the private key whose certificate was uploaded to the
+// connected app
+//
+// See also: https://help.salesforce.com/s/articleView?id=sf.remoteaccess_oauth_jwt_flow.htm
+type JWTBearerFlow struct {
+	// LoginURL defaults to DefaultLoginURL
+	LoginURL string
+	// Audience defaults to LoginURL
+	Audience string
+	// ClientID is the consumer key of the connected app
+	ClientID string
+	// Username is the user to issue tokens for
+	Username   string
+	PrivateKey *rsa.PrivateKey
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// Token implements the TokenSource interface
+func (f *JWTBearerFlow) Token(ctx context.Context) (*Token, error) {
+	assertion, err := f.assertion(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return requestToken(ctx, f.HTTPClient, f.LoginURL, url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	})
+}
+
+// assertion builds the JWT signed with RS256 which is exchanged for a token
+func (f *JWTBearerFlow) assertion(now time.Time) (string, error) {
+	if f.PrivateKey == nil {
+		return "", ErrNoPrivateKey
+	}
+	audience := f.Audience
+	if audience == "" {
+		audience = loginURL(f.LoginURL)
+	}
+	claims, err := json.Marshal(map[string]interface{}{
+		"iss": f.ClientID,
+		"sub": f.Username,
+		"aud": audience,
+		"exp": now.Add(jwtLifetime).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	encoding := base64.RawURLEncoding
+	signingInput := encoding.EncodeToString([]byte(`{"alg":"RS256"}`)) + "." + encoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, f.PrivateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return signingInput + "." + encoding.EncodeToString(signature), nil
+}
+
+// ClientCredentialsFlow obtains tokens with the OAuth 2.0 client credentials
+// flow for the integration user of the connected app
+//
+// See also: https://help.salesforce.com/s/articleView?id=sf.remoteaccess_oauth_client_credentials_flow.htm
+type ClientCredentialsFlow struct {
+	// LoginURL must be your My Domain URL, as this flow is not available
+	// through DefaultLoginURL
+	LoginURL     string
+	ClientID     string
+	ClientSecret string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// Token implements the TokenSource interface
+func (f *ClientCredentialsFlow) Token(ctx context.Context) (*Token, error) {
+	return requestToken(ctx, f.HTTPClient, f.LoginURL, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {f.ClientID},
+		"client_secret": {f.ClientSecret},
+	})
+}
+
+// RefreshTokenFlow obtains tokens with the OAuth 2.0 refresh token flow from
+// a refresh token issued earlier, e.g. by the web server flow
+//
+// See also: https://help.salesforce.com/s/articleView?id=sf.remoteaccess_oauth_refresh_token_flow.htm
+type RefreshTokenFlow struct {
+	// LoginURL defaults to DefaultLoginURL
+	LoginURL     string
+	ClientID     string
+	ClientSecret string
+	RefreshToken string
+	// HTTPClient defaults to http.DefaultClient
+	HTTPClient *http.Client
+}
+
+// Token implements the TokenSource interface
+func (f *RefreshTokenFlow) Token(ctx context.Context) (*Token, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {f.ClientID},
+		"refresh_token": {f.RefreshToken},
+	}
+	// The secret is optional depending on the connected app's settings
+	if f.ClientSecret != "" {
+		form.Set("client_secret", f.ClientSecret)
+	}
+	return requestToken(ctx, f.HTTPClient, f.LoginURL, form)
+}
+
+// ParsePrivateKey parses a PEM encoded RSA private key in either PKCS #1 or
+// PKCS #8 form for use with JWTBearerFlow
+func ParsePrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected an RSA private key but got %T", key)
+	}
+	return rsaKey, nil
+}
+
+// tokenResponse is the body of a successful token request
+type tokenResponse struct {
+	AccessToken string      `json:"access_token"`
+	TokenType   string      `json:"token_type"`
+	InstanceURL string      `json:"instance_url"`
+	ID          string      `json:"id"`
+	IssuedAt    string      `json:"issued_at"`
+	ExpiresIn   json.Number `json:"expires_in"`
+}
+
+// errorResponse is the body of a failed token request
+type errorResponse struct {
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func requestToken(ctx context.Context, client *http.Client, login string, form url.Values) (*Token, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, loginURL(login)+tokenPath, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+
+	resp, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e errorResponse
+		_ = json.Unmarshal(body, &e)
+		return nil, OAuthError{resp.StatusCode, e.Error, e.ErrorDescription}
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return nil, err
+	}
+	if tr.AccessToken == "" {
+		return nil, errors.New("token response did not include an access_token")
+	}
+
+	token := &Token{
+		AccessToken: tr.AccessToken,
+		TokenType:   tr.TokenType,
+		InstanceURL: tr.InstanceURL,
+		ID:          tr.ID,
+		IssuedAt:    time.Now(),
+	}
+	// issued_at is milliseconds since the epoch as a string
+	if ms, err := strconv.ParseInt(tr.IssuedAt, 10, 64); err == nil {
+		token.IssuedAt = time.UnixMilli(ms)
+	}
+	if seconds, err := tr.ExpiresIn.Int64(); err == nil && seconds > 0 {
+		token.Expiry = token.IssuedAt.Add(time.Duration(seconds) * time.Second)
+	}
+	return token, nil
+}
+
+func loginURL(u string) string {
+	if u == "" {
+		return DefaultLoginURL
+	}
+	return strings.TrimSuffix(u, "/")
+}
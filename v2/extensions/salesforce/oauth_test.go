@@ -0,0 +1,218 @@
+package salesforce
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTokenServer starts a stand-in for the Salesforce token endpoint which
+// passes each request's form to check and replies with status and body
+func newTokenServer(t *testing.T, status int, body string, check func(url.Values)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != tokenPath || r.Method != http.MethodPost {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("failed to parse form (%v)", err)
+		}
+		if check != nil {
+			check(r.PostForm)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+const tokenBody = `{
+	"access_token": "00Dxx!token",
+	"instance_url": "https://example.my.salesforce.com",
+	"id": "https://login.salesforce.com/id/00Dxx/005xx",
+	"token_type": "Bearer",
+	"issued_at": "1588314531000",
+	"signature": "abc"
+}`
+
+func TestFlows(t *testing.T) {
+	testCases := []struct {
+		name     string
+		flow     func(loginURL string) TokenSource
+		expected url.Values
+	}{
+		{
+			name: "client credentials",
+			flow: func(loginURL string) TokenSource {
+				return &ClientCredentialsFlow{LoginURL: loginURL, ClientID: "id", ClientSecret: "secret"}
+			},
+			expected: url.Values{"grant_type": {"client_credentials"}, "client_id": {"id"}, "client_secret": {"secret"}},
+		},
+		{
+			name: "refresh token",
+			flow: func(loginURL string) TokenSource {
+				return &RefreshTokenFlow{LoginURL: loginURL + "/", ClientID: "id", RefreshToken: "refresh"}
+			},
+			expected: url.Values{"grant_type": {"refresh_token"}, "client_id": {"id"}, "refresh_token": {"refresh"}},
+		},
+		{
+			name: "refresh token with secret",
+			flow: func(loginURL string) TokenSource {
+				return &RefreshTokenFlow{LoginURL: loginURL, ClientID: "id", ClientSecret: "secret", RefreshToken: "refresh"}
+			},
+			expected: url.Values{"grant_type": {"refresh_token"}, "client_id": {"id"}, "client_secret": {"secret"}, "refresh_token": {"refresh"}},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			server := newTokenServer(t, http.StatusOK, tokenBody, func(form url.Values) {
+				if form.Encode() != tc.expected.Encode() {
+					t.Errorf("expected form %v but got %v", tc.expected, form)
+				}
+			})
+
+			token, err := tc.flow(server.URL).Token(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error (%v)", err)
+			}
+			if token.AccessToken != "00Dxx!token" || token.InstanceURL != "https://example.my.salesforce.com" {
+				t.Errorf("unexpected token %+v", token)
+			}
+			if !token.IssuedAt.Equal(time.UnixMilli(1588314531000)) {
+				t.Errorf("unexpected issued at %v", token.IssuedAt)
+			}
+			if !token.Expiry.IsZero() {
+				t.Errorf("expected no expiry but got %v", token.Expiry)
+			}
+		})
+	}
+}
+
+func TestJWTBearerFlow(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key (%v)", err)
+	}
+
+	var server *httptest.Server
+	server = newTokenServer(t, http.StatusOK, tokenBody, func(form url.Values) {
+		if got := form.Get("grant_type"); got != "urn:ietf:params:oauth:grant-type:jwt-bearer" {
+			t.Errorf("unexpected grant_type %q", got)
+		}
+		parts := strings.Split(form.Get("assertion"), ".")
+		if len(parts) != 3 {
+			t.Fatalf("expected a JWT but got %q", form.Get("assertion"))
+		}
+		signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		if err := rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature); err != nil {
+			t.Errorf("invalid signature (%v)", err)
+		}
+
+		var claims struct {
+			Issuer   string `json:"iss"`
+			Subject  string `json:"sub"`
+			Audience string `json:"aud"`
+			Expiry   int64  `json:"exp"`
+		}
+		payload, _ := base64.RawURLEncoding.DecodeString(parts[1])
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			t.Fatalf("failed to decode claims (%v)", err)
+		}
+		if claims.Issuer != "id" || claims.Subject != "user@example.com" || claims.Audience != server.URL {
+			t.Errorf("unexpected claims %+v", claims)
+		}
+		if exp := time.Unix(claims.Expiry, 0); time.Until(exp) > jwtLifetime {
+			t.Errorf("assertion expires too late at %v", exp)
+		}
+	})
+
+	flow := &JWTBearerFlow{LoginURL: server.URL, ClientID: "id", Username: "user@example.com", PrivateKey: key}
+	if _, err := flow.Token(context.Background()); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+
+	flow.PrivateKey = nil
+	if _, err := flow.Token(context.Background()); !errors.Is(err, ErrNoPrivateKey) {
+		t.Errorf("expected ErrNoPrivateKey but got %v", err)
+	}
+}
+
+func TestTokenRequestErrors(t *testing.T) {
+	server := newTokenServer(t, http.StatusBadRequest, `{"error":"invalid_grant","error_description":"expired access/refresh token"}`, nil)
+	flow := &RefreshTokenFlow{LoginURL: server.URL, ClientID: "id", RefreshToken: "refresh"}
+	_, err := flow.Token(context.Background())
+
+	var oauthErr OAuthError
+	if !errors.As(err, &oauthErr) {
+		t.Fatalf("expected an OAuthError but got %v", err)
+	}
+	if oauthErr.StatusCode != http.StatusBadRequest || oauthErr.ErrorCode != "invalid_grant" {
+		t.Errorf("unexpected error %+v", oauthErr)
+	}
+}
+
+func TestTokenExpiresIn(t *testing.T) {
+	server := newTokenServer(t, http.StatusOK, `{"access_token":"token","issued_at":"1588314531000","expires_in":7200}`, nil)
+	token, err := (&ClientCredentialsFlow{LoginURL: server.URL}).Token(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	if expected := time.UnixMilli(1588314531000).Add(2 * time.Hour); !token.Expiry.Equal(expected) {
+		t.Errorf("expected expiry %v but got %v", expected, token.Expiry)
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key (%v)", err)
+	}
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(key)
+
+	testCases := []struct {
+		name      string
+		pem       []byte
+		shouldErr bool
+	}{
+		{"PKCS #1", pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), false},
+		{"PKCS #8", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}), false},
+		{"not PEM", []byte("not a key"), true},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParsePrivateKey(tc.pem)
+			if tc.shouldErr {
+				if err == nil {
+					t.Fatal("expected an error but received none")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error (%v)", err)
+			}
+			if !got.Equal(key) {
+				t.Error("parsed a different key")
+			}
+		})
+	}
+}
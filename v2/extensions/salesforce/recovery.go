@@ -0,0 +1,89 @@
+package salesforce
+
+import (
+	"time"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+)
+
+// RecoveryExtensionName is the name RecoveryExtension is registered under
+const RecoveryExtensionName = "salesforce-recovery"
+
+// RecoveryExtension makes the Client recover from the errors ParseError
+// recognises by rewriting the advice of the replies they arrive in, since
+// Salesforce does not always advise what to do:
+//
+//   - after an UnknownClientError the Client handshakes again and
+//     resubscribes
+//   - after a DailyLimitError the Client waits until ResetAt before
+//     handshaking again
+//
+// Authentication failures are left to an Authenticator and invalid replay
+// IDs to the replay extension's fallback position.
+//
+//	client.UseExtension(salesforce.NewRecoveryExtension())
+type RecoveryExtension struct {
+	// OnError, if set, is called with each error recognised in a reply
+	OnError func(StreamingError)
+
+	now func() time.Time
+}
+
+var _ bayeux.MessageExtender = (*RecoveryExtension)(nil)
+
+// NewRecoveryExtension creates a RecoveryExtension
+func NewRecoveryExtension() *RecoveryExtension {
+	return &RecoveryExtension{now: time.Now}
+}
+
+// ExtensionName implements the gobayeux.NamedExtension interface
+func (e *RecoveryExtension) ExtensionName() string {
+	return RecoveryExtensionName
+}
+
+// Registered is called when the extension is registered with a client
+func (e *RecoveryExtension) Registered(extensionName string, client *bayeux.BayeuxClient) {
+}
+
+// Unregistered is called when the extension is removed from a client
+func (e *RecoveryExtension) Unregistered() {
+}
+
+// Outgoing leaves messages unchanged
+func (e *RecoveryExtension) Outgoing(ms *bayeux.Message) {
+}
+
+// Incoming advises the Client how to recover from any error in a reply on a
+// meta channel
+func (e *RecoveryExtension) Incoming(ms *bayeux.Message) {
+	if ms.Channel.Type() != bayeux.MetaChannel {
+		return
+	}
+	err := parseError(*ms, e.now())
+	if err == nil {
+		return
+	}
+	if e.OnError != nil {
+		e.OnError(err)
+	}
+
+	advice := bayeux.Advice{}
+	if ms.Advice != nil {
+		advice = *ms.Advice
+	}
+	switch err := err.(type) {
+	case UnknownClientError:
+		advice.Reconnect = bayeux.ReconnectHandshake
+	case DailyLimitError:
+		// A failed handshake is retried while a failed /meta/connect is
+		// followed by a new handshake
+		advice.Reconnect = bayeux.ReconnectHandshake
+		if ms.Channel == bayeux.MetaHandshake {
+			advice.Reconnect = bayeux.ReconnectRetry
+		}
+		advice.Interval = int(err.ResetAt.Sub(e.now()) / time.Millisecond)
+	default:
+		return
+	}
+	ms.Advice = &advice
+}
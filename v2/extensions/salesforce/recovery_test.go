@@ -0,0 +1,136 @@
+package salesforce
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/internal/gobayeuxtest"
+)
+
+func TestRecoveryExtensionIncoming(t *testing.T) {
+	now := time.Date(2020, 5, 1, 23, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name     string
+		message  bayeux.Message
+		expected *bayeux.Advice
+	}{
+		{
+			name:    "unknown client without advice",
+			message: bayeux.Message{Channel: bayeux.MetaConnect, Error: "403::Unknown client"},
+			expected: &bayeux.Advice{
+				Reconnect: bayeux.ReconnectHandshake,
+			},
+		},
+		{
+			name: "unknown client advising retry",
+			message: bayeux.Message{
+				Channel: bayeux.MetaConnect,
+				Error:   "403::Unknown client",
+				Advice:  &bayeux.Advice{Reconnect: bayeux.ReconnectRetry, Timeout: 110000},
+			},
+			expected: &bayeux.Advice{
+				Reconnect: bayeux.ReconnectHandshake,
+				Timeout:   110000,
+			},
+		},
+		{
+			name:    "daily limit on handshake",
+			message: bayeux.Message{Channel: bayeux.MetaHandshake, Error: "403::Organization total events daily limit exceeded"},
+			expected: &bayeux.Advice{
+				Reconnect: bayeux.ReconnectRetry,
+				Interval:  int(time.Hour / time.Millisecond),
+			},
+		},
+		{
+			name:    "daily limit on connect",
+			message: bayeux.Message{Channel: bayeux.MetaConnect, Error: "403::Organization total events daily limit exceeded"},
+			expected: &bayeux.Advice{
+				Reconnect: bayeux.ReconnectHandshake,
+				Interval:  int(time.Hour / time.Millisecond),
+			},
+		},
+		{
+			name:    "authentication invalid",
+			message: bayeux.Message{Channel: bayeux.MetaHandshake, Error: "401::Authentication invalid"},
+		},
+		{
+			name:    "not a meta channel",
+			message: bayeux.Message{Channel: "/service/foo", Error: "403::Unknown client"},
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			var errs []StreamingError
+			e := NewRecoveryExtension()
+			e.now = func() time.Time { return now }
+			e.OnError = func(err StreamingError) { errs = append(errs, err) }
+
+			m := tc.message
+			e.Incoming(&m)
+			if tc.expected == nil {
+				if m.Advice != tc.message.Advice {
+					t.Errorf("expected advice to be unchanged but got %+v", m.Advice)
+				}
+				return
+			}
+			if m.Advice == nil || !reflect.DeepEqual(*m.Advice, *tc.expected) {
+				t.Errorf("expected advice %+v but got %+v", tc.expected, m.Advice)
+			}
+			if len(errs) != 1 {
+				t.Errorf("expected OnError to be called once but got %v", errs)
+			}
+		})
+	}
+}
+
+func TestClientRehandshakesAfterUnknownClient(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := bayeux.NewClient("https://example.my.salesforce.com/cometd/59.0", bayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+	if err := client.UseExtension(NewRecoveryExtension()); err != nil {
+		t.Fatalf("unexpected error adding extension (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+	msgs := make(chan []bayeux.Message)
+	go func() {
+		for range msgs {
+		}
+	}()
+	if _, err := client.SubscribeAndWait(ctx, "/event/Foo__e", msgs); err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	// Lose the session only once there is a subscription to carry over
+	server.FailNextConnect("403::Unknown client")
+
+	for server.Handshakes() < 2 || server.Requests(bayeux.MetaSubscribe) < 2 {
+		select {
+		case err := <-errs:
+			t.Fatalf("unexpected error (%v)", err)
+		case <-ctx.Done():
+			t.Fatalf("client did not handshake and resubscribe again; handshakes=%d subscribes=%d", server.Handshakes(), server.Requests(bayeux.MetaSubscribe))
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if !server.Subscribed("/event/Foo__e") {
+		t.Error("expected the new session to be subscribed to /event/Foo__e")
+	}
+
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatalf("unexpected error disconnecting (%v)", err)
+	}
+}
@@ -4,6 +4,19 @@
 // An example usage looks like:
 //
 //	client := gobayeux.NewClient(serverAddress, gobayeux.WithHTTPTransport(salesforce.StaticTokenAuthenticator{myToken, http.DefaultTransport}))
+//
+// Tokens can instead be obtained, and renewed when they expire, through the
+// JWT bearer, client credentials or refresh token flows:
+//
+//	flow := &salesforce.JWTBearerFlow{ClientID: consumerKey, Username: username, PrivateKey: key}
+//	auth := salesforce.NewAuthenticator(flow, http.DefaultTransport)
+//	client := gobayeux.NewClient(serverAddress, gobayeux.WithHTTPTransport(auth))
+//
+// Errors reported by the Streaming API, such as `403::Unknown client`, are
+// identified by ParseError along with the recommended recovery. Adding a
+// RecoveryExtension makes the Client act on them:
+//
+//	client.UseExtension(salesforce.NewRecoveryExtension())
 package salesforce
 
 import (
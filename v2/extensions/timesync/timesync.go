@@ -0,0 +1,199 @@
+// Package timesync provides the time synchronisation extension for the
+// Bayeux protocol.
+//
+// Every /meta/handshake and /meta/connect request carries the local time it
+// was sent (tc) along with the current estimates of the network latency (l)
+// and of the offset of the server's clock from ours (o). The server replies
+// with the time it received the request (ts) and how long it spent processing
+// it (p), from which the extension refines its estimates.
+//
+// Example Usage:
+//
+//	ts := timesync.New()
+//	client := gobayeux.NewClient(serverAddress)
+//	client.UseExtension(ts)
+//	// ...
+//	local, err := ts.LocalTime(&message)
+//
+// See also: https://docs.cometd.org/current/reference/#_extensions_timesync
+package timesync
+
+import (
+	"sync"
+	"time"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+)
+
+const (
+	// ExtensionName is the name of the time synchronisation extension in
+	// the ext field of messages
+	ExtensionName string = "timesync"
+	// DefaultSamples is how many replies the estimates are averaged over
+	DefaultSamples int = 10
+
+	clientTimeKey     string = "tc"
+	latencyKey        string = "l"
+	offsetKey         string = "o"
+	serverTimeKey     string = "ts"
+	processingTimeKey string = "p"
+)
+
+// Extension implements the time synchronisation extension and keeps the
+// running estimates of latency and clock offset
+type Extension struct {
+	mu         sync.Mutex
+	maxSamples int
+	latencies  []time.Duration
+	offsets    []time.Duration
+	latency    time.Duration
+	offset     time.Duration
+	now        func() time.Time
+}
+
+var _ bayeux.MessageExtender = (*Extension)(nil)
+
+// New creates a new extension which averages its estimates over the last
+// DefaultSamples replies
+func New() *Extension {
+	return NewWithSamples(DefaultSamples)
+}
+
+// NewWithSamples creates a new extension which averages its estimates over
+// the last samples replies
+func NewWithSamples(samples int) *Extension {
+	if samples <= 0 {
+		samples = DefaultSamples
+	}
+	return &Extension{maxSamples: samples, now: time.Now}
+}
+
+// ExtensionName implements the gobayeux.NamedExtension interface
+func (e *Extension) ExtensionName() string {
+	return ExtensionName
+}
+
+// Outgoing attaches the local time and current estimates to /meta/handshake
+// and /meta/connect requests
+func (e *Extension) Outgoing(m *bayeux.Message) {
+	switch m.Channel {
+	case bayeux.MetaHandshake, bayeux.MetaConnect:
+	default:
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	ext := m.GetExt(true)
+	ext[ExtensionName] = map[string]interface{}{
+		clientTimeKey: e.now().UnixMilli(),
+		latencyKey:    e.latency.Milliseconds(),
+		offsetKey:     e.offset.Milliseconds(),
+	}
+}
+
+// Incoming updates the estimates from the server's reply to a
+// /meta/handshake or /meta/connect request
+func (e *Extension) Incoming(m *bayeux.Message) {
+	switch m.Channel {
+	case bayeux.MetaHandshake, bayeux.MetaConnect:
+	default:
+		return
+	}
+
+	fields, ok := m.GetExt(false)[ExtensionName].(map[string]interface{})
+	if !ok {
+		return
+	}
+	tc, ok1 := milliseconds(fields[clientTimeKey])
+	ts, ok2 := milliseconds(fields[serverTimeKey])
+	p, ok3 := milliseconds(fields[processingTimeKey])
+	if !ok1 || !ok2 || !ok3 {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := e.now().UnixMilli()
+	// The request and the reply are assumed to take as long as each other,
+	// so the server received the request half the round trip after tc
+	latency := time.Duration((now-tc-p)/2) * time.Millisecond
+	offset := time.Duration(ts-tc)*time.Millisecond - latency
+	e.latencies = appendSample(e.latencies, latency, e.maxSamples)
+	e.offsets = appendSample(e.offsets, offset, e.maxSamples)
+	e.latency = average(e.latencies)
+	e.offset = average(e.offsets)
+}
+
+// Registered is called after an extension has been successfully registered
+func (e *Extension) Registered(extensionName string, client *bayeux.BayeuxClient) {
+}
+
+// Unregistered is called when an extension is unregistered
+func (e *Extension) Unregistered() {
+}
+
+// Latency returns the estimated one-way network latency to the server
+func (e *Extension) Latency() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.latency
+}
+
+// Offset returns the estimated amount the server's clock is ahead of ours. It
+// is negative when the server's clock is behind.
+func (e *Extension) Offset() time.Duration {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.offset
+}
+
+// ServerTime returns the estimated current time on the server
+func (e *Extension) ServerTime() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.now().Add(e.offset)
+}
+
+// ToLocal converts a time read from the server's clock to local time
+func (e *Extension) ToLocal(serverTime time.Time) time.Time {
+	return serverTime.Add(-e.Offset()).Local()
+}
+
+// LocalTime returns the Timestamp of m converted to local time
+func (e *Extension) LocalTime(m *bayeux.Message) (time.Time, error) {
+	t, err := m.TimestampAsTime()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return e.ToLocal(t), nil
+}
+
+// milliseconds converts a time field decoded from JSON
+func milliseconds(value interface{}) (int64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return int64(v), true
+	case int64:
+		return v, true
+	case int:
+		return int64(v), true
+	}
+	return 0, false
+}
+
+func appendSample(samples []time.Duration, sample time.Duration, limit int) []time.Duration {
+	samples = append(samples, sample)
+	if len(samples) > limit {
+		samples = samples[len(samples)-limit:]
+	}
+	return samples
+}
+
+func average(samples []time.Duration) time.Duration {
+	var total time.Duration
+	for _, s := range samples {
+		total += s
+	}
+	return total / time.Duration(len(samples))
+}
@@ -0,0 +1,130 @@
+package timesync
+
+import (
+	"testing"
+	"time"
+
+	bayeux "github.com/sigmavirus24/gobayeux/v2"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+// roundTrip sends a /meta/connect request through e and replies as a server
+// whose clock is offset ahead of ours, taking latency each way and processing
+// for processing
+func roundTrip(t *testing.T, e *Extension, clock *fakeClock, offset, latency, processing time.Duration) {
+	t.Helper()
+	request := bayeux.Message{Channel: bayeux.MetaConnect}
+	e.Outgoing(&request)
+	fields, ok := request.GetExt(false)[ExtensionName].(map[string]interface{})
+	if !ok {
+		t.Fatal("expected timesync fields on /meta/connect")
+	}
+	tc := fields[clientTimeKey].(int64)
+	if tc != clock.now.UnixMilli() {
+		t.Fatalf("expected tc to be %d but got %d", clock.now.UnixMilli(), tc)
+	}
+
+	received := clock.now.Add(latency)
+	clock.now = received.Add(processing + latency)
+	reply := bayeux.Message{
+		Channel:    bayeux.MetaConnect,
+		Successful: true,
+		Ext: map[string]interface{}{
+			ExtensionName: map[string]interface{}{
+				clientTimeKey:     float64(tc),
+				serverTimeKey:     float64(received.Add(offset).UnixMilli()),
+				processingTimeKey: float64(processing.Milliseconds()),
+			},
+		},
+	}
+	e.Incoming(&reply)
+}
+
+func TestEstimatesOffsetAndLatency(t *testing.T) {
+	clock := &fakeClock{time.Date(2020, 5, 1, 6, 28, 51, 0, time.UTC)}
+	e := NewWithSamples(2)
+	e.now = clock.Now
+
+	testCases := []struct {
+		name            string
+		offset          time.Duration
+		latency         time.Duration
+		expectedOffset  time.Duration
+		expectedLatency time.Duration
+	}{
+		{"server ahead", 3 * time.Second, 100 * time.Millisecond, 3 * time.Second, 100 * time.Millisecond},
+		{"averaged", 3 * time.Second, 300 * time.Millisecond, 3 * time.Second, 200 * time.Millisecond},
+		{"oldest sample dropped", -time.Second, 300 * time.Millisecond, time.Second, 300 * time.Millisecond},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		roundTrip(t, e, clock, tc.offset, tc.latency, 20*time.Millisecond)
+		if got := e.Offset(); got != tc.expectedOffset {
+			t.Errorf("%s: expected offset %v but got %v", tc.name, tc.expectedOffset, got)
+		}
+		if got := e.Latency(); got != tc.expectedLatency {
+			t.Errorf("%s: expected latency %v but got %v", tc.name, tc.expectedLatency, got)
+		}
+	}
+
+	request := bayeux.Message{Channel: bayeux.MetaHandshake}
+	e.Outgoing(&request)
+	fields := request.GetExt(false)[ExtensionName].(map[string]interface{})
+	if got := fields[offsetKey]; got != int64(1000) {
+		t.Errorf("expected o to be 1000 but got %v", got)
+	}
+	if got := fields[latencyKey]; got != int64(300) {
+		t.Errorf("expected l to be 300 but got %v", got)
+	}
+}
+
+func TestIgnoresOtherMessages(t *testing.T) {
+	e := New()
+	m := bayeux.Message{Channel: "/foo/bar"}
+	e.Outgoing(&m)
+	if m.Ext != nil {
+		t.Errorf("expected no ext on %s but got %v", m.Channel, m.Ext)
+	}
+
+	// A server without the extension does not reply with it
+	e.Incoming(&bayeux.Message{Channel: bayeux.MetaConnect, Successful: true})
+	if e.Offset() != 0 || e.Latency() != 0 {
+		t.Errorf("expected no estimates but got offset %v and latency %v", e.Offset(), e.Latency())
+	}
+}
+
+func TestLocalTime(t *testing.T) {
+	clock := &fakeClock{time.Date(2020, 5, 1, 6, 28, 51, 0, time.UTC)}
+	e := New()
+	e.now = clock.Now
+	roundTrip(t, e, clock, 2*time.Second, 50*time.Millisecond, 0)
+
+	m := bayeux.Message{Timestamp: "2020-05-01T06:28:53.00"}
+	got, err := e.LocalTime(&m)
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	expected := time.Date(2020, 5, 1, 6, 28, 51, 0, time.UTC)
+	if !got.Equal(expected) {
+		t.Errorf("expected %v but got %v", expected, got)
+	}
+	if got.Location() != time.Local {
+		t.Errorf("expected local time but got %v", got.Location())
+	}
+
+	if _, err := e.LocalTime(&bayeux.Message{Timestamp: "yesterday"}); err == nil {
+		t.Error("expected an error for an invalid timestamp")
+	}
+
+	if got, expected := e.ServerTime(), clock.now.Add(2*time.Second); !got.Equal(expected) {
+		t.Errorf("expected server time %v but got %v", expected, got)
+	}
+}
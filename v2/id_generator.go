@@ -0,0 +1,53 @@
+package gobayeux
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+)
+
+// IDGenerator generates the id of each message sent to the server so that
+// its reply can be matched to it. IDs must be unique for the lifetime of a
+// BayeuxClient and NextID may be called concurrently.
+//
+// See also: https://docs.cometd.org/current/reference/#_id
+type IDGenerator interface {
+	NextID() string
+}
+
+// CounterIDGenerator generates the IDs "1", "2", "3" and so on. The zero
+// value is ready to use.
+type CounterIDGenerator struct {
+	last atomic.Uint64
+}
+
+// NextID implements the IDGenerator interface
+func (g *CounterIDGenerator) NextID() string {
+	return strconv.FormatUint(g.last.Add(1), 10)
+}
+
+// RandomIDGenerator generates random (version 4) UUIDs, which are unique
+// across clients and so easier to find in server logs
+type RandomIDGenerator struct{}
+
+// NextID implements the IDGenerator interface
+func (RandomIDGenerator) NextID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// defaultIDGenerator is used by request builders which are not given an
+// IDGenerator
+var defaultIDGenerator IDGenerator = &CounterIDGenerator{}
+
+// nextID returns the next ID from ids or the default generator if ids is nil
+func nextID(ids IDGenerator) string {
+	if ids == nil {
+		return defaultIDGenerator.NextID()
+	}
+	return ids.NextID()
+}
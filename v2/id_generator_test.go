@@ -0,0 +1,50 @@
+package gobayeux
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+)
+
+func TestCounterIDGenerator(t *testing.T) {
+	g := &CounterIDGenerator{}
+	for _, want := range []string{"1", "2", "3"} {
+		if got := g.NextID(); got != want {
+			t.Errorf("expected %q but got %q", want, got)
+		}
+	}
+
+	var wg sync.WaitGroup
+	ids := make(chan string, 100)
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ids <- g.NextID()
+		}()
+	}
+	wg.Wait()
+	close(ids)
+	seen := make(map[string]bool)
+	for id := range ids {
+		if seen[id] {
+			t.Errorf("id %q was generated twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+func TestRandomIDGenerator(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		id := RandomIDGenerator{}.NextID()
+		if !uuid.MatchString(id) {
+			t.Errorf("expected a version 4 UUID but got %q", id)
+		}
+		if seen[id] {
+			t.Errorf("id %q was generated twice", id)
+		}
+		seen[id] = true
+	}
+}
@@ -9,10 +9,13 @@ import (
 	"io"
 	"math/rand"
 	"net/http"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/sigmavirus24/gobayeux/v2"
+	"golang.org/x/net/websocket"
 )
 
 const (
@@ -23,9 +26,9 @@ var (
 	chars    = []rune("ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmonpqrstuvwxyz0123456789")
 	numChars = len(chars)
 	advice   = &gobayeux.Advice{
-		Reconnect: "handshake",
-		Timeout:   int(30 * time.Second),
-		Interval:  int(1 * time.Second),
+		Reconnect: "retry",
+		Timeout:   int((30 * time.Second).Milliseconds()),
+		Interval:  0,
 	}
 )
 
@@ -41,13 +44,39 @@ type Server struct {
 	running bool
 	subs    map[string][]gobayeux.Channel
 
-	handshakeError bool
+	handshakeError    bool
+	publishError      bool
+	deniedChannels    []gobayeux.Channel
+	connectionTypes   []string
+	webSocketMessages int
+
+	connectAdvice      *gobayeux.Advice
+	handshakeAdvice    *gobayeux.Advice
+	handshakeRejection string
+	rejectedHandshakes int
+	serviceReply       func(gobayeux.Message) *gobayeux.Message
+	serviceReplies     map[string][]*gobayeux.Message
+	unknownClientEvery int
+	refusedConnects    int
+	refusedAdvice      *gobayeux.Advice
+	connectError       string
+	unavailableFor     int
+	authorize          func(authorization string) bool
+	replay             bool
+	oldestReplayID     int64
+	replayPositions    map[gobayeux.Channel]int64
+	clientID           string
+	handshakes         int
+	connects           int
+	requests           map[gobayeux.Channel]int
 }
 
 func NewServer(logger Logger, opts ...ServerOpts) *Server {
 	server := &Server{
-		log:  logger,
-		subs: make(map[string][]gobayeux.Channel),
+		log:             logger,
+		subs:            make(map[string][]gobayeux.Channel),
+		requests:        make(map[gobayeux.Channel]int),
+		replayPositions: make(map[gobayeux.Channel]int64),
 	}
 
 	for _, opt := range opts {
@@ -90,6 +119,14 @@ func (s *Server) RoundTrip(req *http.Request) (*http.Response, error) {
 		}
 	}()
 
+	if s.authorize != nil && !s.authorize(req.Header.Get("Authorization")) {
+		return &http.Response{
+			StatusCode: http.StatusUnauthorized,
+			Status:     http.StatusText(http.StatusUnauthorized),
+			Body:       io.NopCloser(bytes.NewReader([]byte(`[{"errorCode":"INVALID_SESSION_ID"}]`))),
+		}, nil
+	}
+
 	var msgs []*gobayeux.Message
 
 	body, err := io.ReadAll(req.Body)
@@ -104,30 +141,221 @@ func (s *Server) RoundTrip(req *http.Request) (*http.Response, error) {
 		}, nil
 	}
 
+	replies, statusCode := s.handle(msgs)
+	if replies == nil {
+		return &http.Response{
+			StatusCode: statusCode,
+			Status:     http.StatusText(statusCode),
+			Body:       io.NopCloser(bytes.NewReader([]byte(`{"error":"Invalid request"}`))),
+		}, nil
+	}
+
+	reply, err := json.Marshal(replies)
+	if err != nil {
+		return nil, fmt.Errorf("issue marshaling body (%w)", err)
+	}
+
+	return &http.Response{
+		StatusCode: statusCode,
+		Body:       io.NopCloser(bytes.NewReader(reply)),
+	}, nil
+}
+
+// ServeHTTP allows the server to be used with httptest.NewServer. Requests
+// that ask to be upgraded are served as websocket connections and every other
+// request is handled as a long-polling request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if strings.EqualFold(req.Header.Get("Upgrade"), "websocket") {
+		websocket.Handler(s.serveWebSocket).ServeHTTP(w, req)
+		return
+	}
+
+	resp, err := s.RoundTrip(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(resp.StatusCode)
+	if resp.Body != nil {
+		_, _ = io.Copy(w, resp.Body)
+	}
+}
+
+// Handshakes returns the number of handshake requests received
+func (s *Server) Handshakes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.handshakes
+}
+
+// Connects returns the number of /meta/connect requests received
+func (s *Server) Connects() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.connects
+}
+
+// Requests returns the number of messages received on channel
+func (s *Server) Requests(channel gobayeux.Channel) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[channel]
+}
+
+// ReplayPosition returns the replay ID the last accepted subscription to
+// channel asked to start from
+func (s *Server) ReplayPosition(channel gobayeux.Channel) (int64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	position, ok := s.replayPositions[channel]
+	return position, ok
+}
+
+// Subscribed reports whether the session established by the last successful
+// handshake is subscribed to channel
+func (s *Server) Subscribed(channel gobayeux.Channel) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return slices.Contains(s.subs[s.clientID], channel)
+}
+
+// FailNextConnect makes the next /meta/connect request fail with
+// connectError and no advice, losing the client's session
+func (s *Server) FailNextConnect(connectError string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.connectError = connectError
+}
+
+// WebSocketMessages returns the number of messages received over websocket
+// connections
+func (s *Server) WebSocketMessages() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.webSocketMessages
+}
+
+func (s *Server) serveWebSocket(conn *websocket.Conn) {
+	for {
+		var msgs []*gobayeux.Message
+		if err := websocket.JSON.Receive(conn, &msgs); err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		if !s.running {
+			s.mu.Unlock()
+			return
+		}
+		s.webSocketMessages += len(msgs)
+		replies, _ := s.handle(msgs)
+		s.mu.Unlock()
+
+		if err := websocket.JSON.Send(conn, replies); err != nil {
+			return
+		}
+	}
+}
+
+// handle must be called with s.mu held
+func (s *Server) handle(msgs []*gobayeux.Message) ([]*gobayeux.Message, int) {
 	replies := []*gobayeux.Message{}
 	statusCode := http.StatusOK
 
 	for _, msg := range msgs {
+		s.requests[msg.Channel]++
 		switch msg.Channel {
 		case "/meta/handshake":
+			s.handshakes++
 			if s.handshakeError {
-				return &http.Response{
-					StatusCode: http.StatusBadRequest,
-					Status:     http.StatusText(http.StatusBadRequest),
-					Body:       io.NopCloser(bytes.NewReader([]byte(`{"error":"Invalid request"}`))),
-				}, nil
+				return nil, http.StatusBadRequest
 			}
-			replies = append(replies, &gobayeux.Message{
+			if s.handshakes <= s.rejectedHandshakes {
+				handshakeError := s.handshakeRejection
+				if handshakeError == "" {
+					handshakeError = "403::Handshake denied"
+				}
+				replies = append(replies, &gobayeux.Message{
+					Channel:    "/meta/handshake",
+					Successful: false,
+					Error:      handshakeError,
+					Advice:     s.handshakeAdvice,
+					ID:         msg.ID,
+				})
+				continue
+			}
+
+			connectionTypes := msg.SupportedConnectionTypes
+			if s.connectionTypes != nil {
+				connectionTypes = s.connectionTypes
+			}
+			reply := &gobayeux.Message{
 				Channel:                  "/meta/handshake",
 				Version:                  msg.Version,
-				SupportedConnectionTypes: msg.SupportedConnectionTypes,
+				SupportedConnectionTypes: connectionTypes,
 				ClientID:                 generateID(10),
 				Successful:               true,
 				AuthSuccessful:           true,
 				Advice:                   advice,
 				ID:                       msg.ID,
-			})
+			}
+			if s.replay {
+				reply.Ext = map[string]interface{}{"replay": true}
+			}
+			s.clientID = reply.ClientID
+			replies = append(replies, reply)
 		case "/meta/connect":
+			s.connects++
+			if s.connects <= s.unavailableFor {
+				return nil, http.StatusServiceUnavailable
+			}
+			if s.connects <= s.refusedConnects {
+				replies = append(replies, &gobayeux.Message{
+					Channel:    "/meta/connect",
+					Successful: false,
+					ClientID:   msg.ClientID,
+					Error:      "403::Connect refused",
+					Advice:     s.refusedAdvice,
+					ID:         msg.ID,
+				})
+				continue
+			}
+			if s.unknownClientEvery > 0 && s.connects%s.unknownClientEvery == 0 {
+				// Behave as though the server lost the session
+				delete(s.subs, msg.ClientID)
+				replies = append(replies, &gobayeux.Message{
+					Channel:    "/meta/connect",
+					Successful: false,
+					ClientID:   msg.ClientID,
+					Error:      "402::Unknown client",
+					Advice:     &gobayeux.Advice{Reconnect: "handshake"},
+					ID:         msg.ID,
+				})
+				continue
+			}
+			if connectError := s.connectError; connectError != "" {
+				s.connectError = ""
+				delete(s.subs, msg.ClientID)
+				replies = append(replies, &gobayeux.Message{
+					Channel:    "/meta/connect",
+					Successful: false,
+					ClientID:   msg.ClientID,
+					Error:      connectError,
+					ID:         msg.ID,
+				})
+				continue
+			}
+
+			replies = append(replies, s.serviceReplies[msg.ClientID]...)
+			delete(s.serviceReplies, msg.ClientID)
 			if channels, ok := s.subs[msg.ClientID]; ok {
 				for _, ch := range channels {
 					replies = append(replies, &gobayeux.Message{
@@ -140,11 +368,15 @@ func (s *Server) RoundTrip(req *http.Request) (*http.Response, error) {
 				}
 			}
 
+			connectAdvice := advice
+			if s.connectAdvice != nil {
+				connectAdvice = s.connectAdvice
+			}
 			replies = append(replies, &gobayeux.Message{
 				Channel:    "/meta/connect",
 				Successful: true,
 				ClientID:   msg.ClientID,
-				Advice:     advice,
+				Advice:     connectAdvice,
 				ID:         msg.ID,
 			})
 		case "/meta/subscribe":
@@ -168,6 +400,26 @@ func (s *Server) RoundTrip(req *http.Request) (*http.Response, error) {
 				}
 			}
 
+			if slices.Contains(s.deniedChannels, msg.Subscription) {
+				reply.Successful = false
+				reply.Error = fmt.Sprintf("403:%s,%s:Subscription denied", msg.ClientID, msg.Subscription)
+				replies = append(replies, reply)
+				continue
+			}
+
+			if s.replay {
+				position, ok := replayPosition(msg)
+				if ok && position > 0 && position < s.oldestReplayID {
+					reply.Successful = false
+					reply.Error = fmt.Sprintf("400::The replayId {%d} you provided was invalid.  Please provide a valid ID, -2 to replay all events, or -1 to replay only new events.", position)
+					replies = append(replies, reply)
+					continue
+				}
+				if ok {
+					s.replayPositions[msg.Subscription] = position
+				}
+			}
+
 			s.subs[msg.ClientID] = append(s.subs[msg.ClientID], msg.Subscription)
 
 			replies = append(replies, reply)
@@ -214,19 +466,45 @@ func (s *Server) RoundTrip(req *http.Request) (*http.Response, error) {
 				Successful: true,
 			})
 		default:
-			s.log.Logf("unhandled: %+v", msg)
+			if msg.Channel.Type() == gobayeux.MetaChannel {
+				s.log.Logf("unhandled: %+v", msg)
+				continue
+			}
+
+			reply := &gobayeux.Message{
+				Channel:    msg.Channel,
+				ID:         msg.ID,
+				ClientID:   msg.ClientID,
+				Successful: true,
+			}
+
+			if s.publishError {
+				reply.Successful = false
+				reply.Error = "403::Publish denied"
+			} else if s.serviceReply != nil && msg.Channel.Type() == gobayeux.ServiceChannel {
+				if serviceReply := s.serviceReply(*msg); serviceReply != nil {
+					if s.serviceReplies == nil {
+						s.serviceReplies = make(map[string][]*gobayeux.Message)
+					}
+					s.serviceReplies[msg.ClientID] = append(s.serviceReplies[msg.ClientID], serviceReply)
+				}
+			}
+
+			replies = append(replies, reply)
 		}
 	}
 
-	reply, err := json.Marshal(replies)
-	if err != nil {
-		return nil, fmt.Errorf("issue marshaling body (%w)", err)
-	}
+	return replies, statusCode
+}
 
-	return &http.Response{
-		StatusCode: statusCode,
-		Body:       io.NopCloser(bytes.NewReader(reply)),
-	}, nil
+// replayPosition returns the replay ID sent for the channel msg subscribes to
+func replayPosition(msg *gobayeux.Message) (int64, bool) {
+	positions, ok := msg.GetExt(false)["replay"].(map[string]interface{})
+	if !ok {
+		return 0, false
+	}
+	position, ok := positions[string(msg.Subscription)].(float64)
+	return int64(position), ok
 }
 
 func generateID(length int) string {
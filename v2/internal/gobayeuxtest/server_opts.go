@@ -1,5 +1,7 @@
 package gobayeuxtest
 
+import "github.com/sigmavirus24/gobayeux/v2"
+
 type ServerOpts interface {
 	apply(s *Server)
 }
@@ -15,3 +17,99 @@ func WithHandshakeError(handshakeError bool) ServerOpts {
 		s.handshakeError = handshakeError
 	})
 }
+
+func WithPublishError(publishError bool) ServerOpts {
+	return serverOptFn(func(s *Server) {
+		s.publishError = publishError
+	})
+}
+
+func WithSupportedConnectionTypes(connectionTypes ...string) ServerOpts {
+	return serverOptFn(func(s *Server) {
+		s.connectionTypes = connectionTypes
+	})
+}
+
+// WithConnectAdvice sets the advice included in successful /meta/connect
+// replies
+func WithConnectAdvice(advice *gobayeux.Advice) ServerOpts {
+	return serverOptFn(func(s *Server) {
+		s.connectAdvice = advice
+	})
+}
+
+// WithHandshakeAdvice makes the first n handshake requests fail with
+// advice
+func WithHandshakeAdvice(n int, advice *gobayeux.Advice) ServerOpts {
+	return serverOptFn(func(s *Server) {
+		s.rejectedHandshakes = n
+		s.handshakeAdvice = advice
+	})
+}
+
+// WithHandshakeRejection sets the error of the handshake replies rejected
+// with WithHandshakeAdvice
+func WithHandshakeRejection(handshakeError string) ServerOpts {
+	return serverOptFn(func(s *Server) {
+		s.handshakeRejection = handshakeError
+	})
+}
+
+// WithRefusedConnects makes the first n /meta/connect requests fail with
+// advice
+func WithRefusedConnects(n int, advice *gobayeux.Advice) ServerOpts {
+	return serverOptFn(func(s *Server) {
+		s.refusedConnects = n
+		s.refusedAdvice = advice
+	})
+}
+
+// WithUnknownClientEvery makes every nth /meta/connect request fail as
+// though the server had lost the client's session, advising the client to
+// handshake again
+func WithUnknownClientEvery(n int) ServerOpts {
+	return serverOptFn(func(s *Server) {
+		s.unknownClientEvery = n
+	})
+}
+
+// WithUnavailableConnects makes the first n /meta/connect requests fail with
+// a 503 Service Unavailable response
+func WithUnavailableConnects(n int) ServerOpts {
+	return serverOptFn(func(s *Server) {
+		s.unavailableFor = n
+	})
+}
+
+// WithDeniedChannels makes the server reject subscriptions to channels
+func WithDeniedChannels(channels ...gobayeux.Channel) ServerOpts {
+	return serverOptFn(func(s *Server) {
+		s.deniedChannels = channels
+	})
+}
+
+// WithReplay makes the server support the replay extension, rejecting
+// subscriptions which ask to replay from before oldestReplayID
+func WithReplay(oldestReplayID int64) ServerOpts {
+	return serverOptFn(func(s *Server) {
+		s.replay = true
+		s.oldestReplayID = oldestReplayID
+	})
+}
+
+// WithAuthorization makes the server reject requests with a 401 Unauthorized
+// response unless authorize accepts their Authorization header
+func WithAuthorization(authorize func(authorization string) bool) ServerOpts {
+	return serverOptFn(func(s *Server) {
+		s.authorize = authorize
+	})
+}
+
+// WithServiceReplies makes the server answer messages published to service
+// channels with the message reply returns, if any, which is delivered with
+// the next /meta/connect response
+func WithServiceReplies(reply func(gobayeux.Message) *gobayeux.Message) ServerOpts {
+	return serverOptFn(func(s *Server) {
+		s.serviceReply = reply
+	})
+}
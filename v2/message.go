@@ -172,21 +172,38 @@ type Advice struct {
 	Hosts []string `json:"hosts,omitempty"`
 }
 
+const (
+	// ReconnectRetry is the reconnect advice to retry /meta/connect
+	ReconnectRetry string = "retry"
+	// ReconnectHandshake is the reconnect advice to handshake again
+	ReconnectHandshake string = "handshake"
+	// ReconnectNone is the reconnect advice to neither retry nor handshake
+	ReconnectNone string = "none"
+)
+
+// defaultAdvice is followed until the server provides advice
+var defaultAdvice = Advice{Reconnect: ReconnectRetry}
+
 // MustNotRetryOrHandshake indicates whether neither a handshake or retry is
 // allowed
 func (a Advice) MustNotRetryOrHandshake() bool {
-	return a.Reconnect == "none"
+	return a.Reconnect == ReconnectNone
 }
 
 // ShouldRetry indicates whether a retry should occur
 func (a Advice) ShouldRetry() bool {
-	return a.Reconnect == "retry"
+	return a.Reconnect == ReconnectRetry
 }
 
 // ShouldHandshake indicates whether the advice is that a handshake should
 // occur
 func (a Advice) ShouldHandshake() bool {
-	return a.Reconnect == "handshake"
+	return a.Reconnect == ReconnectHandshake
+}
+
+func (a Advice) withReconnect(reconnect string) Advice {
+	a.Reconnect = reconnect
+	return a
 }
 
 // TimeoutAsDuration returns the Timeout field as a time.Duration for
@@ -217,4 +234,6 @@ const (
 	ConnectionTypeCallbackPolling = "callback-polling"
 	// ConnectionTypeIFrame is a constant for the iframe string
 	ConnectionTypeIFrame = "iframe"
+	// ConnectionTypeWebSocket is a constant for the websocket string
+	ConnectionTypeWebSocket = "websocket"
 )
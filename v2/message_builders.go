@@ -15,6 +15,7 @@ type HandshakeRequestBuilder struct {
 	supportedConnectionTypes []string
 	// Optional fields
 	minimumVersion string
+	ids            IDGenerator
 }
 
 // NewHandshakeRequestBuilder provides an easy way to build a Message that can
@@ -29,21 +30,21 @@ func NewHandshakeRequestBuilder() *HandshakeRequestBuilder {
 // AddSupportedConnectionType accepts a string and will add it to the list of
 // supported connection types for the /meta/handshake request. It validates
 // the connection type. You're encouraged to use one of the constants created
-// for these different connection types.
+// for these different connection types, though any valid name is accepted
+// for connection types of your own.
 // This will de-duplicate connection types and returns an error if an invalid
 // connection type was provided.
 func (b *HandshakeRequestBuilder) AddSupportedConnectionType(connectionType string) error {
-	switch connectionType {
-	case ConnectionTypeCallbackPolling, ConnectionTypeLongPolling, ConnectionTypeIFrame:
-		for _, ct := range b.supportedConnectionTypes {
-			if ct == connectionType {
-				return nil
-			}
+	if err := validateConnectionType(connectionType); err != nil {
+		return err
+	}
+
+	for _, ct := range b.supportedConnectionTypes {
+		if ct == connectionType {
+			return nil
 		}
-		b.supportedConnectionTypes = append(b.supportedConnectionTypes, connectionType)
-	default:
-		return BadConnectionTypeError{connectionType}
 	}
+	b.supportedConnectionTypes = append(b.supportedConnectionTypes, connectionType)
 	return nil
 }
 
@@ -68,6 +69,12 @@ func (b *HandshakeRequestBuilder) AddMinimumVersion(version string) error {
 	return nil
 }
 
+// AddIDGenerator sets the generator of the id given to each message in the
+// request. Without one, IDs come from a counter shared by every builder.
+func (b *HandshakeRequestBuilder) AddIDGenerator(ids IDGenerator) {
+	b.ids = ids
+}
+
 // Build generates the final Message to be sent as a Handshake Request
 func (b *HandshakeRequestBuilder) Build() ([]Message, error) {
 	if len(b.supportedConnectionTypes) < 1 {
@@ -80,12 +87,13 @@ func (b *HandshakeRequestBuilder) Build() ([]Message, error) {
 		Channel:                  MetaHandshake,
 		Version:                  b.version,
 		SupportedConnectionTypes: b.supportedConnectionTypes,
+		ID:                       nextID(b.ids),
 	}
 	if len(b.minimumVersion) > 0 {
 		m.MinimumVersion = b.minimumVersion
 	}
-	// TODO After we've added methods for id, ext, and minimumVersion, update
-	// those values in the struct here as well
+	// TODO After we've added methods for ext, update that value in the
+	// struct here as well
 	return []Message{m}, nil
 }
 
@@ -95,6 +103,7 @@ func (b *HandshakeRequestBuilder) Build() ([]Message, error) {
 type ConnectRequestBuilder struct {
 	clientID       string
 	connectionType string
+	ids            IDGenerator
 }
 
 // NewConnectRequestBuilder initializes a ConnectRequestBuilder as an easy way
@@ -113,16 +122,21 @@ func (b *ConnectRequestBuilder) AddClientID(clientID string) {
 // AddConnectionType adds the connection type used by the client for the
 // purposes of this connection to the request
 func (b *ConnectRequestBuilder) AddConnectionType(connectionType string) error {
-	switch connectionType {
-	case ConnectionTypeCallbackPolling, ConnectionTypeLongPolling, ConnectionTypeIFrame:
-		b.connectionType = connectionType
-	default:
-		return BadConnectionTypeError{connectionType}
+	if err := validateConnectionType(connectionType); err != nil {
+		return err
 	}
+
+	b.connectionType = connectionType
 	return nil
 }
 
-// TODO Add methods for id and ext
+// AddIDGenerator sets the generator of the id given to each message in the
+// request. Without one, IDs come from a counter shared by every builder.
+func (b *ConnectRequestBuilder) AddIDGenerator(ids IDGenerator) {
+	b.ids = ids
+}
+
+// TODO Add methods for ext
 
 // Build generates the final Message to be sent as a Connect Request
 func (b *ConnectRequestBuilder) Build() ([]Message, error) {
@@ -138,9 +152,10 @@ func (b *ConnectRequestBuilder) Build() ([]Message, error) {
 		Channel:        MetaConnect,
 		ClientID:       b.clientID,
 		ConnectionType: b.connectionType,
+		ID:             nextID(b.ids),
 	}
-	// TODO After we've added methods for id and ext, update
-	// those values in the struct here as well
+	// TODO After we've added methods for ext, update that value in the
+	// struct here as well
 	return []Message{m}, nil
 }
 
@@ -150,6 +165,7 @@ func (b *ConnectRequestBuilder) Build() ([]Message, error) {
 type SubscribeRequestBuilder struct {
 	clientID     string
 	subscription []Channel
+	ids          IDGenerator
 }
 
 // NewSubscribeRequestBuilder initializes a SubscribeRequestBuilder as an easy
@@ -180,6 +196,12 @@ func (b *SubscribeRequestBuilder) AddSubscription(c Channel) error {
 	return nil
 }
 
+// AddIDGenerator sets the generator of the id given to each message in the
+// request. Without one, IDs come from a counter shared by every builder.
+func (b *SubscribeRequestBuilder) AddIDGenerator(ids IDGenerator) {
+	b.ids = ids
+}
+
 // Build generates the final Message to be sent as a Subscribe Request
 func (b *SubscribeRequestBuilder) Build() ([]Message, error) {
 	if b.clientID == "" {
@@ -197,11 +219,11 @@ func (b *SubscribeRequestBuilder) Build() ([]Message, error) {
 			Channel:      MetaSubscribe,
 			ClientID:     b.clientID,
 			Subscription: b.subscription[i],
+			ID:           nextID(b.ids),
 		}
 	}
 
-	// TODO Add the ext and id fields once we're able to handle them with the
-	// builder
+	// TODO Add the ext field once we're able to handle it with the builder
 	return ms, nil
 }
 
@@ -211,6 +233,7 @@ func (b *SubscribeRequestBuilder) Build() ([]Message, error) {
 type UnsubscribeRequestBuilder struct {
 	clientID     string
 	subscription []Channel
+	ids          IDGenerator
 }
 
 // NewUnsubscribeRequestBuilder initializes a SubscribeRequestBuilder as an easy
@@ -241,6 +264,12 @@ func (b *UnsubscribeRequestBuilder) AddSubscription(c Channel) error {
 	return nil
 }
 
+// AddIDGenerator sets the generator of the id given to each message in the
+// request. Without one, IDs come from a counter shared by every builder.
+func (b *UnsubscribeRequestBuilder) AddIDGenerator(ids IDGenerator) {
+	b.ids = ids
+}
+
 // Build generates the final Message to be sent as a Unsubscribe Request
 func (b *UnsubscribeRequestBuilder) Build() ([]Message, error) {
 	if b.clientID == "" {
@@ -258,10 +287,10 @@ func (b *UnsubscribeRequestBuilder) Build() ([]Message, error) {
 			Channel:      MetaUnsubscribe,
 			ClientID:     b.clientID,
 			Subscription: b.subscription[i],
+			ID:           nextID(b.ids),
 		}
 	}
-	// TODO Add the ext and id fields once we're able to handle them with the
-	// builder
+	// TODO Add the ext field once we're able to handle it with the builder
 	return ms, nil
 }
 
@@ -270,6 +299,7 @@ func (b *UnsubscribeRequestBuilder) Build() ([]Message, error) {
 // https://docs.cometd.org/current/reference/#_bayeux_meta_disconnect
 type DisconnectRequestBuilder struct {
 	clientID string
+	ids      IDGenerator
 }
 
 // NewDisconnectRequestBuilder initializes a DisconnectRequestBuilder as an
@@ -283,13 +313,110 @@ func (b *DisconnectRequestBuilder) AddClientID(clientID string) {
 	b.clientID = clientID
 }
 
+// AddIDGenerator sets the generator of the id given to each message in the
+// request. Without one, IDs come from a counter shared by every builder.
+func (b *DisconnectRequestBuilder) AddIDGenerator(ids IDGenerator) {
+	b.ids = ids
+}
+
 // Build generates the final Message to be sent as a Disconnect Request
 func (b *DisconnectRequestBuilder) Build() ([]Message, error) {
 	if b.clientID == "" {
 		return nil, ErrMissingClientID
 	}
 
-	return []Message{{Channel: MetaDisconnect, ClientID: b.clientID}}, nil
+	return []Message{{Channel: MetaDisconnect, ClientID: b.clientID, ID: nextID(b.ids)}}, nil
+}
+
+// PublishRequestBuilder provides an easy way to build a set of Messages that
+// can be sent as publish requests per the specification in
+// https://docs.cometd.org/current/reference/#_publish_request
+type PublishRequestBuilder struct {
+	clientID string
+	messages []Message
+	ids      IDGenerator
+}
+
+// NewPublishRequestBuilder initializes a PublishRequestBuilder as an easy way
+// to build Messages that can be sent as publish requests. See also
+// https://docs.cometd.org/current/reference/#_publish_request
+func NewPublishRequestBuilder() *PublishRequestBuilder {
+	return &PublishRequestBuilder{messages: make([]Message, 0)}
+}
+
+// AddClientID adds the previously provided clientId to the request
+func (b *PublishRequestBuilder) AddClientID(clientID string) {
+	b.clientID = clientID
+}
+
+// AddMessage adds a message to be published. The message must be destined
+// for a valid, non-wildcard channel that is not a meta channel and it must
+// carry data.
+func (b *PublishRequestBuilder) AddMessage(m Message) error {
+	if !m.Channel.IsValid() || m.Channel.HasWildcard() || m.Channel.Type() == MetaChannel {
+		return InvalidChannelError{m.Channel}
+	}
+
+	if len(m.Data) == 0 {
+		return ErrMissingData
+	}
+
+	b.messages = append(b.messages, m)
+	return nil
+}
+
+// AddIDGenerator sets the generator of the id given to each message in the
+// request. Without one, IDs come from a counter shared by every builder.
+func (b *PublishRequestBuilder) AddIDGenerator(ids IDGenerator) {
+	b.ids = ids
+}
+
+// Build generates the final Messages to be sent as a Publish Request
+func (b *PublishRequestBuilder) Build() ([]Message, error) {
+	if b.clientID == "" {
+		return nil, ErrMissingClientID
+	}
+
+	if len(b.messages) < 1 {
+		return nil, EmptySliceError("messages")
+	}
+
+	ms := make([]Message, len(b.messages))
+	for i, m := range b.messages {
+		ms[i] = Message{
+			Channel:  m.Channel,
+			ClientID: b.clientID,
+			Data:     m.Data,
+			ID:       m.ID,
+			Ext:      m.Ext,
+		}
+		// Messages keep any ID they were given, e.g. by Client.Call
+		if ms[i].ID == "" {
+			ms[i].ID = nextID(b.ids)
+		}
+	}
+	return ms, nil
+}
+
+// validateConnectionType checks that connectionType is a name the Bayeux
+// grammar allows, which is a letter followed by any number of letters,
+// digits, "-" and "_". Whether the server supports it is only known once it
+// replies to the handshake.
+//
+// See also: https://docs.cometd.org/current/reference/#_bayeux_supported_connections
+func validateConnectionType(connectionType string) error {
+	if connectionType == "" {
+		return BadConnectionTypeError{connectionType}
+	}
+	for i, r := range connectionType {
+		switch {
+		case 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case i > 0 && ('0' <= r && r <= '9' || r == '-' || r == '_'):
+		default:
+			return BadConnectionTypeError{connectionType}
+		}
+	}
+	return nil
 }
 
 func validateVersion(version string) error {
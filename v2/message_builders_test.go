@@ -24,8 +24,23 @@ func TestHandshakeRequestBuilder_AddSupportedConnectionType(t *testing.T) {
 			false,
 		},
 		{
-			"invalid connection type",
-			"invalid-polling",
+			"custom connection type",
+			"in-process_2",
+			false,
+		},
+		{
+			"empty connection type",
+			"",
+			true,
+		},
+		{
+			"connection type starting with a digit",
+			"2-polling",
+			true,
+		},
+		{
+			"connection type with a space",
+			"long polling",
 			true,
 		},
 	}
@@ -97,3 +112,89 @@ func TestHandshakeRequestBuilder_AddVersion(t *testing.T) {
 		})
 	}
 }
+
+func TestPublishRequestBuilder_AddMessage(t *testing.T) {
+	testCases := []struct {
+		name      string
+		message   Message
+		shouldErr bool
+	}{
+		{
+			"valid broadcast channel",
+			Message{Channel: "/foo/bar", Data: []byte(`{}`)},
+			false,
+		},
+		{
+			"valid service channel",
+			Message{Channel: "/service/foo", Data: []byte(`{}`)},
+			false,
+		},
+		{
+			"meta channel",
+			Message{Channel: MetaConnect, Data: []byte(`{}`)},
+			true,
+		},
+		{
+			"wildcard channel",
+			Message{Channel: "/foo/*", Data: []byte(`{}`)},
+			true,
+		},
+		{
+			"invalid channel",
+			Message{Channel: "foo", Data: []byte(`{}`)},
+			true,
+		},
+		{
+			"missing data",
+			Message{Channel: "/foo/bar"},
+			true,
+		},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewPublishRequestBuilder()
+			err := b.AddMessage(tc.message)
+			if err != nil && !tc.shouldErr {
+				t.Errorf("expected message to be valid but got err %q", err)
+			}
+			if err == nil && tc.shouldErr {
+				t.Error("expected an error but didn't get one")
+			}
+		})
+	}
+}
+
+func TestPublishRequestBuilder_Build(t *testing.T) {
+	b := NewPublishRequestBuilder()
+	if _, err := b.Build(); err != ErrMissingClientID {
+		t.Errorf("expected ErrMissingClientID but got %v", err)
+	}
+
+	b.AddClientID("Un1q31d3nt1f13r")
+	if _, err := b.Build(); err == nil {
+		t.Error("expected an error building without messages but didn't get one")
+	}
+}
+
+func TestPublishRequestBuilder_KeepsIDs(t *testing.T) {
+	b := NewPublishRequestBuilder()
+	b.AddIDGenerator(&CounterIDGenerator{})
+	b.AddClientID("Un1q31d3nt1f13r")
+	for _, id := range []string{"", "call-1", ""} {
+		if err := b.AddMessage(Message{Channel: "/foo/bar", Data: []byte(`{}`), ID: id}); err != nil {
+			t.Fatalf("unexpected error (%v)", err)
+		}
+	}
+
+	ms, err := b.Build()
+	if err != nil {
+		t.Fatalf("unexpected error (%v)", err)
+	}
+	for i, want := range []string{"1", "call-1", "2"} {
+		if ms[i].ID != want {
+			t.Errorf("expected message %d to have id %q but got %q", i, want, ms[i].ID)
+		}
+	}
+}
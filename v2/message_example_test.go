@@ -7,6 +7,7 @@ import (
 
 func ExampleHandshakeRequestBuilder() {
 	b := NewHandshakeRequestBuilder()
+	b.AddIDGenerator(&CounterIDGenerator{})
 	if err := b.AddSupportedConnectionType(ConnectionTypeLongPolling); err != nil {
 		return
 	}
@@ -23,11 +24,12 @@ func ExampleHandshakeRequestBuilder() {
 	}
 	fmt.Println(string(jsonBytes))
 	// Output:
-	// [{"channel":"/meta/handshake","version":"1.0","supportedConnectionTypes":["long-polling"]}]
+	// [{"id":"1","channel":"/meta/handshake","version":"1.0","supportedConnectionTypes":["long-polling"]}]
 }
 
 func ExampleConnectRequestBuilder() {
 	b := NewConnectRequestBuilder()
+	b.AddIDGenerator(&CounterIDGenerator{})
 	if err := b.AddConnectionType(ConnectionTypeLongPolling); err != nil {
 		return
 	}
@@ -42,11 +44,12 @@ func ExampleConnectRequestBuilder() {
 	}
 	fmt.Println(string(jsonBytes))
 	// Output:
-	// [{"channel":"/meta/connect","clientId":"Un1q31d3nt1f13r","connectionType":"long-polling"}]
+	// [{"id":"1","channel":"/meta/connect","clientId":"Un1q31d3nt1f13r","connectionType":"long-polling"}]
 }
 
 func ExampleSubscribeRequestBuilder() {
 	b := NewSubscribeRequestBuilder()
+	b.AddIDGenerator(&CounterIDGenerator{})
 	if err := b.AddSubscription("/foo/**"); err != nil {
 		return
 	}
@@ -67,11 +70,12 @@ func ExampleSubscribeRequestBuilder() {
 	}
 	fmt.Println(string(jsonBytes))
 	// Output:
-	// [{"channel":"/meta/subscribe","clientId":"Un1q31d3nt1f13r","subscription":"/foo/**"},{"channel":"/meta/subscribe","clientId":"Un1q31d3nt1f13r","subscription":"/bar/foo"}]
+	// [{"id":"1","channel":"/meta/subscribe","clientId":"Un1q31d3nt1f13r","subscription":"/foo/**"},{"id":"2","channel":"/meta/subscribe","clientId":"Un1q31d3nt1f13r","subscription":"/bar/foo"}]
 }
 
 func ExampleUnsubscribeRequestBuilder() {
 	b := NewUnsubscribeRequestBuilder()
+	b.AddIDGenerator(&CounterIDGenerator{})
 	if err := b.AddSubscription("/foo/**"); err != nil {
 		return
 	}
@@ -92,5 +96,25 @@ func ExampleUnsubscribeRequestBuilder() {
 	}
 	fmt.Println(string(jsonBytes))
 	// Output:
-	// [{"channel":"/meta/unsubscribe","clientId":"Un1q31d3nt1f13r","subscription":"/foo/**"},{"channel":"/meta/unsubscribe","clientId":"Un1q31d3nt1f13r","subscription":"/bar/foo"}]
+	// [{"id":"1","channel":"/meta/unsubscribe","clientId":"Un1q31d3nt1f13r","subscription":"/foo/**"},{"id":"2","channel":"/meta/unsubscribe","clientId":"Un1q31d3nt1f13r","subscription":"/bar/foo"}]
+}
+
+func ExamplePublishRequestBuilder() {
+	b := NewPublishRequestBuilder()
+	b.AddIDGenerator(&CounterIDGenerator{})
+	if err := b.AddMessage(Message{Channel: "/foo/bar", Data: json.RawMessage(`{"hello":"world"}`)}); err != nil {
+		return
+	}
+	b.AddClientID("Un1q31d3nt1f13r")
+	m, err := b.Build()
+	if err != nil {
+		return
+	}
+	jsonBytes, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	fmt.Println(string(jsonBytes))
+	// Output:
+	// [{"id":"1","channel":"/foo/bar","clientId":"Un1q31d3nt1f13r","data":{"hello":"world"}}]
 }
@@ -52,5 +52,5 @@ func ExampleWithSlogLogger() {
 	}
 	// Output:
 	// level=DEBUG msg=starting at=handshake
-	// level=DEBUG msg="error parsing response" at=handshake error=EOF
+	// level=DEBUG msg="error during request" at=handshake error=EOF
 }
@@ -0,0 +1,113 @@
+package gobayeux
+
+import (
+	"context"
+	"sync"
+)
+
+// MessageHandler is called with each message delivered on a channel a
+// listener was registered for with Client.On
+type MessageHandler func(context.Context, Message)
+
+// SubscriptionResult is the server's reply to a request to subscribe to, or
+// unsubscribe from, a single channel
+type SubscriptionResult struct {
+	Channel    Channel
+	Successful bool
+	// Error is the error the server rejected the request with, when it
+	// could be parsed
+	Error *MessageError
+}
+
+// Subscription is a handle to a listener registered with Client.On
+type Subscription struct {
+	client     *Client
+	channel    Channel
+	subscriber *subscriber
+	once       sync.Once
+	// stopped is closed once handler will no longer be called
+	stopped chan struct{}
+}
+
+// On registers handler as a listener for messages delivered on ch, which may
+// be a wildcard channel. Any number of listeners may be registered on the same
+// channel; the server is only asked to subscribe for the first one.
+//
+// handler is called in its own goroutine, one message at a time, with a
+// context that is cancelled once the listener is unsubscribed or the client
+// shuts down. A slow handler holds up every other subscription unless
+// another DeliveryPolicy is chosen with WithDeliveryPolicy.
+func (c *Client) On(ch Channel, handler MessageHandler, opts ...SubscribeOption) (*Subscription, error) {
+	if !ch.IsValid() || ch.Type() == MetaChannel {
+		return nil, InvalidChannelError{ch}
+	}
+
+	s := &Subscription{
+		client:     c,
+		channel:    ch,
+		subscriber: newChannelSubscriber(make(chan []Message, 1), opts),
+		stopped:    make(chan struct{}),
+	}
+	select {
+	case c.subscribeRequestChannel <- subscriptionRequest{ch, s.subscriber, nil}:
+	case <-c.shutdown:
+		return nil, ErrClientNotConnected
+	}
+
+	go s.listen(handler)
+	return s, nil
+}
+
+// Channel returns the channel the listener was registered for
+func (s *Subscription) Channel() Channel {
+	return s.channel
+}
+
+// Stats returns the listener's delivery counters
+func (s *Subscription) Stats() DeliveryStats {
+	return s.subscriber.stats()
+}
+
+// Unsubscribe stops the listener from receiving any further messages. If it
+// was the last listener on its channel, the client unsubscribes from the
+// channel on the server. Calling Unsubscribe more than once has no effect.
+func (s *Subscription) Unsubscribe() {
+	s.once.Do(func() {
+		s.subscriber.stop()
+		select {
+		case s.client.unsubscribeRequestChannel <- unsubscriptionRequest{s.channel, s.subscriber, nil}:
+		case <-s.client.shutdown:
+		}
+	})
+}
+
+func (s *Subscription) listen(handler MessageHandler) {
+	defer close(s.stopped)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-s.subscriber.done:
+		case <-s.client.shutdown:
+		}
+		cancel()
+	}()
+
+	for {
+		select {
+		case batch := <-s.subscriber.msgs:
+			for _, m := range batch {
+				// Stop as soon as we are unsubscribed, even part
+				// way through a batch
+				select {
+				case <-s.subscriber.done:
+					return
+				default:
+				}
+				handler(ctx, m)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
@@ -2,40 +2,140 @@ package gobayeux
 
 import (
 	"fmt"
+	"slices"
 	"sync"
 )
 
+// subscriber receives the messages delivered on the channel it subscribed to.
+// done is closed once it stops receiving, e.g., after unsubscribing, so that
+// deliveries never block on a subscriber that has gone away.
+type subscriber struct {
+	msgs     chan []Message
+	done     chan struct{}
+	stopOnce sync.Once
+	policy   DeliveryPolicy
+	queue    *deliveryQueue
+	counters deliveryCounters
+}
+
+func newSubscriber(msgs chan []Message) *subscriber {
+	return &subscriber{msgs: msgs, done: make(chan struct{})}
+}
+
+// withPolicy sets the policy batches are delivered to s with
+func (s *subscriber) withPolicy(policy DeliveryPolicy) *subscriber {
+	s.policy = policy
+	if policy.Strategy != DeliveryBlock {
+		s.queue = &deliveryQueue{ready: make(chan struct{}, 1)}
+	}
+	return s
+}
+
+func (s *subscriber) stop() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// subscriptionsMap tracks every subscriber for each channel. The server is
+// only told about the first subscriber to a channel and the last one to
+// leave it.
 type subscriptionsMap struct {
 	lock sync.RWMutex
-	subs map[Channel]chan []Message
+	subs map[Channel][]*subscriber
+	trie *channelTrie
 }
 
 func newSubscriptionsMap() *subscriptionsMap {
-	return &subscriptionsMap{subs: make(map[Channel]chan []Message)}
+	return &subscriptionsMap{
+		subs: make(map[Channel][]*subscriber),
+		trie: newChannelTrie(),
+	}
 }
 
-func (sm *subscriptionsMap) Add(channel Channel, ms chan []Message) error {
+// Add registers s as a subscriber to channel and reports whether it is the
+// first one
+func (sm *subscriptionsMap) Add(channel Channel, s *subscriber) (bool, error) {
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
-	if _, ok := sm.subs[channel]; !ok {
-		sm.subs[channel] = ms
-		return nil
+	subs := sm.subs[channel]
+	if slices.ContainsFunc(subs, func(existing *subscriber) bool { return existing.msgs == s.msgs && s.msgs != nil }) {
+		return false, fmt.Errorf("channel '%s' already subscribed", channel)
 	}
-	return fmt.Errorf("channel '%s' already subscribed", channel)
+	sm.subs[channel] = append(subs, s)
+	sm.trie.Insert(channel, sm.subs[channel])
+	return len(subs) == 0, nil
 }
 
+// Remove unregisters every subscriber to channel
 func (sm *subscriptionsMap) Remove(channel Channel) {
 	sm.lock.Lock()
 	defer sm.lock.Unlock()
+	for _, s := range sm.subs[channel] {
+		s.stop()
+	}
 	delete(sm.subs, channel)
+	sm.trie.Delete(channel)
+}
+
+// RemoveSubscriber unregisters s from channel. It reports whether s was
+// subscribed and how many subscribers to channel remain.
+func (sm *subscriptionsMap) RemoveSubscriber(channel Channel, s *subscriber) (bool, int) {
+	sm.lock.Lock()
+	defer sm.lock.Unlock()
+	s.stop()
+	subs := sm.subs[channel]
+	i := slices.Index(subs, s)
+	if i == -1 {
+		return false, len(subs)
+	}
+
+	subs = slices.Delete(slices.Clone(subs), i, i+1)
+	if len(subs) == 0 {
+		delete(sm.subs, channel)
+		sm.trie.Delete(channel)
+		return true, 0
+	}
+	sm.subs[channel] = subs
+	sm.trie.Insert(channel, subs)
+	return true, len(subs)
+}
+
+// Find returns the subscriber to channel that receives on msgs, if any
+func (sm *subscriptionsMap) Find(channel Channel, msgs chan []Message) (*subscriber, bool) {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+	i := slices.IndexFunc(sm.subs[channel], func(s *subscriber) bool { return s.msgs == msgs })
+	if i == -1 {
+		return nil, false
+	}
+	return sm.subs[channel][i], true
+}
+
+// Count returns the number of subscribers to channel
+func (sm *subscriptionsMap) Count(channel Channel) int {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+	return len(sm.subs[channel])
+}
+
+// Match returns the subscribers to every channel, including wildcard
+// channels, that matches channel
+func (sm *subscriptionsMap) Match(channel Channel) []*subscriber {
+	sm.lock.RLock()
+	defer sm.lock.RUnlock()
+	return sm.trie.Match(channel)
 }
 
-func (sm *subscriptionsMap) Get(channel Channel) (chan []Message, error) {
+// Channels returns every subscribed channel excluding meta channels
+func (sm *subscriptionsMap) Channels() []Channel {
 	sm.lock.RLock()
 	defer sm.lock.RUnlock()
-	ms, ok := sm.subs[channel]
-	if !ok {
-		return nil, fmt.Errorf("channel '%s' has no subscriptions", channel)
+	channels := make([]Channel, 0, len(sm.subs))
+	for channel := range sm.subs {
+		if channel.Type() != MetaChannel {
+			channels = append(channels, channel)
+		}
 	}
-	return ms, nil
+	return channels
 }
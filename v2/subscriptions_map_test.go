@@ -4,27 +4,41 @@ import "testing"
 
 func TestSubscriptionsMap_Add(t *testing.T) {
 	sm := newSubscriptionsMap()
-	want := make(chan []Message)
-	defer close(want)
-	if err := sm.Add("/foo/bar", want); err != nil {
+	want := newSubscriber(make(chan []Message))
+	first, err := sm.Add("/foo/bar", want)
+	if err != nil {
 		t.Errorf("expected successful addition but got err %q", err)
 	}
+	if !first {
+		t.Error("expected the first subscriber to be reported as such")
+	}
 
 	got, ok := sm.subs["/foo/bar"]
 	if !ok {
 		t.Error("channel was not registered properly")
 	}
 
-	if want != got {
-		t.Error("chan received was not the chan registered")
+	if len(got) != 1 || want != got[0] {
+		t.Error("subscriber received was not the subscriber registered")
+	}
+
+	if _, err := sm.Add("/foo/bar", newSubscriber(want.msgs)); err == nil {
+		t.Error("expected an error subscribing the same chan twice")
+	}
+
+	first, err = sm.Add("/foo/bar", newSubscriber(make(chan []Message)))
+	if err != nil {
+		t.Errorf("expected successful addition but got err %q", err)
+	}
+	if first {
+		t.Error("expected the second subscriber not to be reported as the first")
 	}
 }
 
 func TestSubscriptionsMap_Remove(t *testing.T) {
 	sm := newSubscriptionsMap()
-	want := make(chan []Message)
-	defer close(want)
-	if err := sm.Add("/foo/bar", want); err != nil {
+	want := newSubscriber(make(chan []Message))
+	if _, err := sm.Add("/foo/bar", want); err != nil {
 		t.Errorf("unable to add subscription for test: %q", err)
 	}
 
@@ -37,36 +51,52 @@ func TestSubscriptionsMap_Remove(t *testing.T) {
 	if ls := len(sm.subs); ls != 0 {
 		t.Errorf("expected ls to be 0, got %d", ls)
 	}
+
+	select {
+	case <-want.done:
+	default:
+		t.Error("expected the removed subscriber to be stopped")
+	}
 }
 
-func TestSubscriptionsMap_Get(t *testing.T) {
+func TestSubscriptionsMap_RemoveSubscriber(t *testing.T) {
 	sm := newSubscriptionsMap()
-	if _, err := sm.Get("/foo/bar"); err == nil {
-		t.Error("expected '/foo/bar' to not have a subscription, but had one")
+	first := newSubscriber(make(chan []Message))
+	second := newSubscriber(make(chan []Message))
+	for _, s := range []*subscriber{first, second} {
+		if _, err := sm.Add("/foo/bar", s); err != nil {
+			t.Fatalf("unable to add subscription for test: %q", err)
+		}
 	}
 
-	want := make(chan []Message)
-	sm.subs["/foo/bar"] = want
-	if got, err := sm.Get("/foo/bar"); want != got {
-		if err != nil {
-			t.Errorf("expected Get(\"/foo/bar\") to return without error but got %q", err)
-		} else {
-			t.Error("chan retrieved was not the chan registered")
-		}
+	if removed, remaining := sm.RemoveSubscriber("/foo/bar", first); !removed || remaining != 1 {
+		t.Errorf("expected 1 remaining subscriber after removal, got %t, %d", removed, remaining)
+	}
+	if removed, _ := sm.RemoveSubscriber("/foo/bar", first); removed {
+		t.Error("expected removing a subscriber twice to be a no-op")
+	}
+	if got := sm.Match("/foo/bar"); len(got) != 1 || got[0] != second {
+		t.Errorf("expected only the second subscriber to match, got %v", got)
+	}
+	if removed, remaining := sm.RemoveSubscriber("/foo/bar", second); !removed || remaining != 0 {
+		t.Errorf("expected no remaining subscribers after removal, got %t, %d", removed, remaining)
+	}
+	if count := sm.Count("/foo/bar"); count != 0 {
+		t.Errorf("expected no subscribers, got %d", count)
 	}
 }
 
 func BenchmarkSubscriptionsMapAddToEmpty(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		sm := newSubscriptionsMap()
-		_ = sm.Add("/foo/bar", nil)
+		_, _ = sm.Add("/foo/bar", newSubscriber(nil))
 	}
 }
 
 func BenchmarkSubscriptionsMapAddNewToNonEmpty(b *testing.B) {
 	for i := 0; i < b.N; i++ {
 		sm := &subscriptionsMap{
-			subs: map[Channel](chan []Message){
+			subs: map[Channel][]*subscriber{
 				"/":           nil,
 				"/foo":        nil,
 				"/bar":        nil,
@@ -90,14 +120,39 @@ func BenchmarkSubscriptionsMapAddNewToNonEmpty(b *testing.B) {
 				"/bar/baz/*":  nil,
 				"/baz/bar/*":  nil,
 			},
+			trie: newChannelTrie(),
 		}
-		_ = sm.Add("/foo/bar", nil)
+		_, _ = sm.Add("/foo/bar", newSubscriber(nil))
 	}
 }
 
 func BenchmarkSubscriptionsMapAddDuplicate(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		sm := &subscriptionsMap{subs: map[Channel](chan []Message){"/foo/bar": nil}}
-		_ = sm.Add("/foo/bar", nil)
+		sm := &subscriptionsMap{subs: map[Channel][]*subscriber{"/foo/bar": nil}, trie: newChannelTrie()}
+		_, _ = sm.Add("/foo/bar", newSubscriber(nil))
+	}
+}
+
+func TestSubscriptionsMap_Match(t *testing.T) {
+	sm := newSubscriptionsMap()
+	wildcard := newSubscriber(make(chan []Message))
+	exact := newSubscriber(make(chan []Message))
+	if _, err := sm.Add("/foo/*", wildcard); err != nil {
+		t.Fatalf("unable to add subscription for test: %q", err)
+	}
+	if _, err := sm.Add("/foo/bar", exact); err != nil {
+		t.Fatalf("unable to add subscription for test: %q", err)
+	}
+
+	if got := sm.Match("/foo/bar"); len(got) != 2 {
+		t.Errorf("expected 2 matching subscriptions, got %d", len(got))
+	}
+
+	sm.Remove("/foo/bar")
+	if got := sm.Match("/foo/bar"); len(got) != 1 || got[0] != wildcard {
+		t.Errorf("expected only the wildcard subscription to match, got %v", got)
+	}
+	if got := sm.Match("/bar/foo"); len(got) != 0 {
+		t.Errorf("expected no matching subscriptions, got %d", len(got))
 	}
 }
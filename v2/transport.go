@@ -0,0 +1,151 @@
+package gobayeux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// Transport carries batches of messages to and from a Bayeux server using a
+// particular connection type. A BayeuxClient applies its extensions before
+// handing a batch to a Transport and after receiving the reply, so
+// implementations only need to move messages.
+//
+// Implementations must be safe for concurrent use since publishing happens
+// independently of the /meta/connect loop.
+//
+// See also: https://docs.cometd.org/current/reference/#_bayeux_supported_connections
+type Transport interface {
+	// ConnectionType is the name this transport is advertised as during
+	// the handshake. It is either one of the ConnectionType constants or a
+	// name of its own, which must start with a letter followed by letters,
+	// digits, "-" or "_". The transport is only used if the server also
+	// lists it in its reply to the handshake.
+	ConnectionType() string
+	// Send delivers the batch of messages to the server and returns the
+	// messages the server sent in reply
+	Send(ctx context.Context, ms []Message) ([]Message, error)
+	// Close releases any resources held by the transport
+	Close() error
+}
+
+// RelocatableTransport is implemented by transports that can be pointed at a
+// different server. When a BayeuxClient fails over to an alternate server it
+// relocates every registered transport that implements it.
+type RelocatableTransport interface {
+	Transport
+	// SetServerAddress sends every subsequent request to serverAddress
+	SetServerAddress(serverAddress *url.URL)
+}
+
+// LongPollingTransport implements the long-polling connection type by sending
+// each batch as an HTTP POST request. It is the default Transport for a
+// BayeuxClient.
+//
+// See also: https://docs.cometd.org/current/reference/#_long_polling
+type LongPollingTransport struct {
+	client        *http.Client
+	serverAddress *url.URL
+	logger        Logger
+	lock          sync.RWMutex
+}
+
+// NewLongPollingTransport initializes a LongPollingTransport that sends
+// requests to serverAddress with client. If client is nil,
+// http.DefaultClient is used.
+func NewLongPollingTransport(client *http.Client, serverAddress string, logger Logger) (*LongPollingTransport, error) {
+	parsedAddress, err := url.Parse(serverAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if logger == nil {
+		logger = newNullLogger()
+	}
+
+	return newLongPollingTransport(client, parsedAddress, logger), nil
+}
+
+func newLongPollingTransport(client *http.Client, serverAddress *url.URL, logger Logger) *LongPollingTransport {
+	return &LongPollingTransport{
+		client:        client,
+		serverAddress: serverAddress,
+		logger:        logger,
+	}
+}
+
+// ConnectionType implements the Transport interface
+func (t *LongPollingTransport) ConnectionType() string {
+	return ConnectionTypeLongPolling
+}
+
+// Send implements the Transport interface
+func (t *LongPollingTransport) Send(ctx context.Context, ms []Message) ([]Message, error) {
+	resp, err := t.request(ctx, ms)
+	if err != nil {
+		return nil, err
+	}
+	return t.parseResponse(resp)
+}
+
+// Close implements the Transport interface
+func (t *LongPollingTransport) Close() error {
+	return nil
+}
+
+// SetServerAddress implements the RelocatableTransport interface
+func (t *LongPollingTransport) SetServerAddress(serverAddress *url.URL) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.serverAddress = serverAddress
+}
+
+func (t *LongPollingTransport) request(ctx context.Context, ms []Message) (*http.Response, error) {
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(ms); err != nil {
+		return nil, err
+	}
+
+	t.lock.RLock()
+	serverAddress := t.serverAddress.String()
+	t.lock.RUnlock()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", serverAddress, &buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	return t.client.Do(req)
+}
+
+func (t *LongPollingTransport) parseResponse(resp *http.Response) ([]Message, error) {
+	messages := make([]Message, 0)
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			t.logger.WithError(err).Warn("could not close response body")
+		}
+	}()
+
+	if resp.StatusCode != 200 {
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.logger.WithError(err).Debug("error reading body")
+		}
+
+		return nil, BadResponseError{resp.StatusCode, resp.Status, body}
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
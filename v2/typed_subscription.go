@@ -0,0 +1,68 @@
+package gobayeux
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// TypedEvent is a message delivered by SubscribeTyped along with its Data
+// decoded into T
+type TypedEvent[T any] struct {
+	Data T
+	// Message is the message as it was received, for its Channel, ID, Ext
+	// and so on
+	Message Message
+}
+
+// TypedSubscription is a handle to a listener registered with SubscribeTyped
+type TypedSubscription struct {
+	*Subscription
+	errors chan DecodeError
+}
+
+// Errors returns the channel that receives a DecodeError for each message
+// whose Data could not be decoded. It must be received from, like the
+// events, or delivery waits for it. It is closed along with the events.
+func (s *TypedSubscription) Errors() <-chan DecodeError {
+	return s.errors
+}
+
+// SubscribeTyped registers a listener for messages delivered on ch, as On
+// does, which decodes the JSON in each message's Data into T. Decoded
+// messages are sent on the returned channel in the order they were
+// received. Messages that could not be decoded are reported on Errors
+// instead. Both channels are closed once the listener is unsubscribed or the
+// client shuts down.
+//
+//	events, sub, err := gobayeux.SubscribeTyped[OrderEvent](client, "/event/Order__e")
+//	for event := range events {
+//		fmt.Println(event.Message.ID, event.Data.OrderNumber)
+//	}
+func SubscribeTyped[T any](c *Client, ch Channel, opts ...SubscribeOption) (<-chan TypedEvent[T], *TypedSubscription, error) {
+	events := make(chan TypedEvent[T])
+	decodeErrors := make(chan DecodeError)
+	sub, err := c.On(ch, func(ctx context.Context, m Message) {
+		var data T
+		if err := json.Unmarshal(m.Data, &data); err != nil {
+			select {
+			case decodeErrors <- DecodeError{m, err}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		select {
+		case events <- TypedEvent[T]{data, m}:
+		case <-ctx.Done():
+		}
+	}, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	go func() {
+		<-sub.stopped
+		close(events)
+		close(decodeErrors)
+	}()
+	return events, &TypedSubscription{sub, decodeErrors}, nil
+}
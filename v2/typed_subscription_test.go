@@ -0,0 +1,130 @@
+package gobayeux_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sigmavirus24/gobayeux/v2"
+	"github.com/sigmavirus24/gobayeux/v2/internal/gobayeuxtest"
+)
+
+func TestSubscribeTyped(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+
+	// The server sends `{}` which decodes into a struct but not a slice
+	type payload struct {
+		Name string `json:"name"`
+	}
+	events, decoded, err := gobayeux.SubscribeTyped[payload](client, "/foo/bar")
+	if err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+	undecodable, failed, err := gobayeux.SubscribeTyped[[]int](client, "/foo/baz")
+	if err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+
+	select {
+	case event := <-events:
+		if event.Message.Channel != "/foo/bar" || event.Message.ID == "" {
+			t.Errorf("expected the original message but got %+v", event.Message)
+		}
+	case event := <-undecodable:
+		t.Fatalf("unexpected event %+v", event)
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for an event")
+	}
+
+	select {
+	case err := <-failed.Errors():
+		if err.Message.Channel != "/foo/baz" || err.Err == nil {
+			t.Errorf("unexpected decode error %+v", err)
+		}
+	case err := <-decoded.Errors():
+		t.Fatalf("unexpected decode error (%v)", err)
+	case err := <-errs:
+		t.Fatalf("unexpected error from client (%v)", err)
+	case <-ctx.Done():
+		t.Fatal("timeout waiting for a decode error")
+	}
+
+	// Both channels are closed once unsubscribed
+	decoded.Unsubscribe()
+	for range events {
+	}
+	for range decoded.Errors() {
+	}
+
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+	for range undecodable {
+	}
+	for range failed.Errors() {
+	}
+}
+
+func TestSubscribeTypedWaitsForDecodeErrors(t *testing.T) {
+	server := gobayeuxtest.NewServer(t)
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("failed to start test server (%v)", err)
+	}
+
+	client, err := gobayeux.NewClient("https://example.com", gobayeux.WithHTTPTransport(server))
+	if err != nil {
+		t.Fatalf("failed to create client (%v)", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	errs := client.Start(ctx)
+
+	_, failed, err := gobayeux.SubscribeTyped[[]int](client, "/foo/baz")
+	if err != nil {
+		t.Fatalf("failed to subscribe (%v)", err)
+	}
+
+	// While nobody receives the errors, delivery waits for them rather
+	// than dropping any
+	for !server.Subscribed("/foo/baz") {
+		time.Sleep(10 * time.Millisecond)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if stats := failed.Stats(); stats.Delivered > 2 || stats.Dropped > 0 {
+		t.Errorf("expected delivery to wait for the errors but got %+v", stats)
+	}
+
+	for received := 0; received < 3; received++ {
+		select {
+		case err := <-failed.Errors():
+			if err.Message.Channel != "/foo/baz" {
+				t.Errorf("unexpected decode error %+v", err)
+			}
+		case err := <-errs:
+			t.Fatalf("unexpected error from client (%v)", err)
+		case <-ctx.Done():
+			t.Fatal("timeout waiting for decode errors")
+		}
+	}
+
+	if err := client.Disconnect(context.Background()); err != nil {
+		t.Fatalf("failed to disconnect (%v)", err)
+	}
+	for range failed.Errors() {
+	}
+}
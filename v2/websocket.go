@@ -0,0 +1,337 @@
+package gobayeux
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// WebSocketTransport implements the websocket connection type. Every request
+// is carried over a single socket which is dialed lazily and re-dialed if it
+// is lost. Because the server may write to the socket at any time, replies
+// are matched to requests by message id, while messages delivered on
+// subscribed channels are held until the outstanding /meta/connect request
+// collects them. Requests other than /meta/connect wait for their replies for
+// no longer than the network delay allowed for a /meta/connect.
+//
+// See also: https://docs.cometd.org/current/reference/#_websocket_transport
+type WebSocketTransport struct {
+	client        *http.Client
+	serverAddress *url.URL
+	logger        Logger
+	// replyTimeout bounds how long Send waits for replies
+	replyTimeout time.Duration
+
+	writeLock sync.Mutex
+
+	mu           sync.Mutex
+	conn         *websocket.Conn
+	nextID       uint64
+	pending      map[string]pendingReply
+	connectID    string
+	connectReply *Message
+	deliveries   []Message
+	notify       chan struct{}
+}
+
+type pendingReply struct {
+	channel Channel
+	reply   chan Message
+}
+
+// NewWebSocketTransport initializes a WebSocketTransport for the http(s)
+// serverAddress which is dialed using the equivalent ws(s) scheme. Cookies
+// stored in the client's jar and its TLS configuration are used when dialing.
+// If client is nil, http.DefaultClient is used.
+func NewWebSocketTransport(client *http.Client, serverAddress string, logger Logger) (*WebSocketTransport, error) {
+	parsedAddress, err := url.Parse(serverAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if logger == nil {
+		logger = newNullLogger()
+	}
+
+	return newWebSocketTransport(client, parsedAddress, logger), nil
+}
+
+func newWebSocketTransport(client *http.Client, serverAddress *url.URL, logger Logger) *WebSocketTransport {
+	return &WebSocketTransport{
+		client:        client,
+		serverAddress: serverAddress,
+		logger:        logger,
+		replyTimeout:  maxNetworkDelay,
+		pending:       make(map[string]pendingReply),
+		notify:        make(chan struct{}, 1),
+	}
+}
+
+// ConnectionType implements the Transport interface
+func (t *WebSocketTransport) ConnectionType() string {
+	return ConnectionTypeWebSocket
+}
+
+// Send implements the Transport interface
+func (t *WebSocketTransport) Send(ctx context.Context, ms []Message) ([]Message, error) {
+	conn, err := t.connection(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ms) == 1 && ms[0].Channel == MetaConnect {
+		return t.connect(ctx, conn, ms[0])
+	}
+
+	// Replies that never come, e.g. because the socket stalls, must not
+	// hold up callers without a deadline of their own
+	ctx, cancel := context.WithTimeout(ctx, t.replyTimeout)
+	defer cancel()
+
+	replies := make([]chan Message, len(ms))
+	t.mu.Lock()
+	if t.conn != conn {
+		// The socket was lost after we got it so nothing would fail
+		// these replies
+		t.mu.Unlock()
+		return nil, ErrConnectionClosed
+	}
+	for i := range ms {
+		t.assignID(&ms[i])
+		replies[i] = make(chan Message, 1)
+		t.pending[ms[i].ID] = pendingReply{ms[i].Channel, replies[i]}
+	}
+	t.mu.Unlock()
+	defer func() {
+		t.mu.Lock()
+		for _, m := range ms {
+			delete(t.pending, m.ID)
+		}
+		t.mu.Unlock()
+	}()
+
+	if err := t.write(ctx, conn, ms); err != nil {
+		return nil, err
+	}
+
+	response := make([]Message, 0, len(ms))
+	for _, reply := range replies {
+		select {
+		case m, ok := <-reply:
+			if !ok {
+				return response, ErrConnectionClosed
+			}
+			response = append(response, m)
+		case <-ctx.Done():
+			return response, ctx.Err()
+		}
+	}
+	return response, nil
+}
+
+// connect sends the /meta/connect request unless one is already outstanding
+// on the socket and then waits for either its reply or for messages delivered
+// by the server, whichever comes first. This keeps delivery latency low while
+// never having more than one /meta/connect outstanding.
+func (t *WebSocketTransport) connect(ctx context.Context, conn *websocket.Conn, m Message) ([]Message, error) {
+	t.mu.Lock()
+	outstanding := t.connectID != ""
+	if !outstanding {
+		t.assignID(&m)
+		t.connectID = m.ID
+	}
+	t.mu.Unlock()
+
+	if !outstanding {
+		if err := t.write(ctx, conn, []Message{m}); err != nil {
+			t.mu.Lock()
+			t.connectID = ""
+			t.mu.Unlock()
+			return nil, err
+		}
+	}
+
+	for {
+		t.mu.Lock()
+		if t.conn != conn {
+			t.mu.Unlock()
+			return nil, ErrConnectionClosed
+		}
+		if t.connectReply != nil || len(t.deliveries) > 0 {
+			response := t.deliveries
+			t.deliveries = nil
+			if t.connectReply != nil {
+				response = append(response, *t.connectReply)
+				t.connectReply = nil
+				t.connectID = ""
+			}
+			t.mu.Unlock()
+			return response, nil
+		}
+		t.mu.Unlock()
+
+		select {
+		case <-t.notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Close implements the Transport interface
+func (t *WebSocketTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn == nil {
+		return nil
+	}
+	err := t.conn.Close()
+	t.reset()
+	return err
+}
+
+// SetServerAddress implements the RelocatableTransport interface. An open
+// socket to the previous server is closed.
+func (t *WebSocketTransport) SetServerAddress(serverAddress *url.URL) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.serverAddress = serverAddress
+	if t.conn != nil {
+		_ = t.conn.Close()
+		t.reset()
+		t.signal()
+	}
+}
+
+// connection returns the open socket, dialing the server if necessary
+func (t *WebSocketTransport) connection(ctx context.Context) (*websocket.Conn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.conn != nil {
+		return t.conn, nil
+	}
+
+	config, err := websocket.NewConfig(webSocketAddress(t.serverAddress), t.serverAddress.String())
+	if err != nil {
+		return nil, err
+	}
+	if t.client.Jar != nil {
+		for _, cookie := range t.client.Jar.Cookies(t.serverAddress) {
+			config.Header.Add("Cookie", cookie.String())
+		}
+	}
+	if tr, ok := t.client.Transport.(*http.Transport); ok && tr.TLSClientConfig != nil {
+		config.TlsConfig = tr.TLSClientConfig.Clone()
+	}
+
+	conn, err := config.DialContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	t.logger.WithField("address", config.Location).Debug("websocket connected")
+	t.conn = conn
+	go t.read(conn)
+	return conn, nil
+}
+
+func (t *WebSocketTransport) write(ctx context.Context, conn *websocket.Conn, ms []Message) error {
+	t.writeLock.Lock()
+	defer t.writeLock.Unlock()
+	deadline, _ := ctx.Deadline()
+	if err := conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	return websocket.JSON.Send(conn, ms)
+}
+
+// read routes every message received on conn until the socket fails or is
+// closed
+func (t *WebSocketTransport) read(conn *websocket.Conn) {
+	for {
+		var ms []Message
+		if err := websocket.JSON.Receive(conn, &ms); err != nil {
+			t.mu.Lock()
+			if t.conn == conn {
+				t.logger.WithError(err).Debug("websocket closed")
+				_ = conn.Close()
+				t.reset()
+			}
+			t.mu.Unlock()
+			t.signal()
+			return
+		}
+
+		t.mu.Lock()
+		for _, m := range ms {
+			t.route(m)
+		}
+		t.mu.Unlock()
+		t.signal()
+	}
+}
+
+// route must be called with t.mu held
+func (t *WebSocketTransport) route(m Message) {
+	if m.Channel == MetaConnect {
+		if t.connectID == "" {
+			t.logger.WithField("id", m.ID).Debug("dropping unexpected /meta/connect reply")
+			return
+		}
+		t.connectReply = &m
+		return
+	}
+
+	if p, ok := t.pending[m.ID]; ok && p.channel == m.Channel {
+		delete(t.pending, m.ID)
+		p.reply <- m
+		return
+	}
+	t.deliveries = append(t.deliveries, m)
+}
+
+// reset must be called with t.mu held
+func (t *WebSocketTransport) reset() {
+	t.conn = nil
+	t.connectID = ""
+	t.connectReply = nil
+	for id, p := range t.pending {
+		close(p.reply)
+		delete(t.pending, id)
+	}
+}
+
+// assignID must be called with t.mu held
+func (t *WebSocketTransport) assignID(m *Message) {
+	if m.ID != "" {
+		return
+	}
+	t.nextID++
+	m.ID = strconv.FormatUint(t.nextID, 10)
+}
+
+func (t *WebSocketTransport) signal() {
+	select {
+	case t.notify <- struct{}{}:
+	default:
+	}
+}
+
+func webSocketAddress(serverAddress *url.URL) string {
+	u := *serverAddress
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	}
+	return u.String()
+}
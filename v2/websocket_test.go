@@ -0,0 +1,247 @@
+package gobayeux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// newWebSocketPeer starts a server whose end of every socket the returned
+// transport dials is handed to the test through conns
+func newWebSocketPeer(t *testing.T) (*WebSocketTransport, <-chan *websocket.Conn) {
+	t.Helper()
+	conns := make(chan *websocket.Conn, 2)
+	stop := make(chan struct{})
+	server := httptest.NewServer(websocket.Handler(func(conn *websocket.Conn) {
+		conns <- conn
+		// The socket is closed once the handler returns
+		<-stop
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(stop) })
+
+	serverAddress, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatalf("failed to parse server address (%v)", err)
+	}
+	transport := newWebSocketTransport(&http.Client{}, serverAddress, newNullLogger())
+	t.Cleanup(func() { _ = transport.Close() })
+	return transport, conns
+}
+
+type sendResult struct {
+	ms  []Message
+	err error
+}
+
+// sendAsync sends ms over transport in the background
+func sendAsync(ctx context.Context, transport *WebSocketTransport, ms ...Message) <-chan sendResult {
+	result := make(chan sendResult, 1)
+	go func() {
+		response, err := transport.Send(ctx, ms)
+		result <- sendResult{response, err}
+	}()
+	return result
+}
+
+func accept(t *testing.T, conns <-chan *websocket.Conn) *websocket.Conn {
+	t.Helper()
+	select {
+	case conn := <-conns:
+		return conn
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for the transport to dial")
+		return nil
+	}
+}
+
+func receive(t *testing.T, conn *websocket.Conn) []Message {
+	t.Helper()
+	if err := conn.SetReadDeadline(time.Now().Add(5 * time.Second)); err != nil {
+		t.Fatalf("failed to set read deadline (%v)", err)
+	}
+	var ms []Message
+	if err := websocket.JSON.Receive(conn, &ms); err != nil {
+		t.Fatalf("failed to receive messages (%v)", err)
+	}
+	return ms
+}
+
+func reply(t *testing.T, conn *websocket.Conn, ms ...Message) {
+	t.Helper()
+	if err := websocket.JSON.Send(conn, ms); err != nil {
+		t.Fatalf("failed to send messages (%v)", err)
+	}
+}
+
+func result(t *testing.T, results <-chan sendResult) sendResult {
+	t.Helper()
+	select {
+	case r := <-results:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("timeout waiting for Send to return")
+		return sendResult{}
+	}
+}
+
+// ids returns the id of each of ms
+func ids(ms []Message) []string {
+	ids := make([]string, 0, len(ms))
+	for _, m := range ms {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func TestWebSocketTransport_MatchesRepliesByID(t *testing.T) {
+	transport, conns := newWebSocketPeer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	results := sendAsync(ctx, transport,
+		Message{Channel: MetaSubscribe, ID: "1", Subscription: "/foo/bar"},
+		Message{Channel: "/foo/bar", ID: "2", Data: []byte(`{}`)},
+	)
+	conn := accept(t, conns)
+	if got := ids(receive(t, conn)); len(got) != 2 {
+		t.Fatalf("expected both messages in one batch but got %v", got)
+	}
+	// Replies arrive out of order along with deliveries, one of which shares
+	// the id of a request on another channel
+	reply(t, conn, Message{Channel: "/foo/bar", ID: "2", Successful: true})
+	reply(t, conn,
+		Message{Channel: "/foo/baz", ID: "1", Data: []byte(`{}`)},
+		Message{Channel: MetaSubscribe, ID: "1", Subscription: "/foo/bar", Successful: true},
+	)
+
+	r := result(t, results)
+	if r.err != nil {
+		t.Fatalf("unexpected error (%v)", r.err)
+	}
+	if len(r.ms) != 2 || r.ms[0].Channel != MetaSubscribe || r.ms[1].Channel != "/foo/bar" {
+		t.Errorf("expected the replies in the order of the requests but got %+v", r.ms)
+	}
+}
+
+func TestWebSocketTransport_RepliesTimeOut(t *testing.T) {
+	transport, conns := newWebSocketPeer(t)
+	transport.replyTimeout = 50 * time.Millisecond
+
+	// The caller has no deadline of its own
+	results := sendAsync(context.Background(), transport, Message{Channel: "/foo/bar", ID: "1", Data: []byte(`{}`)})
+	conn := accept(t, conns)
+	receive(t, conn)
+
+	if r := result(t, results); !errors.Is(r.err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded but got %v", r.err)
+	}
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if len(transport.pending) != 0 {
+		t.Errorf("expected no pending replies but got %d", len(transport.pending))
+	}
+}
+
+func TestWebSocketTransport_BuffersDeliveries(t *testing.T) {
+	transport, conns := newWebSocketPeer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// Messages delivered while no /meta/connect is waiting are held for
+	// the next one
+	results := sendAsync(ctx, transport, Message{Channel: MetaSubscribe, ID: "1", Subscription: "/foo/bar"})
+	conn := accept(t, conns)
+	receive(t, conn)
+	reply(t, conn,
+		Message{Channel: "/foo/bar", ID: "d1", Data: []byte(`{}`)},
+		Message{Channel: MetaSubscribe, ID: "1", Subscription: "/foo/bar", Successful: true},
+		Message{Channel: "/foo/bar", ID: "d2", Data: []byte(`{}`)},
+	)
+	if r := result(t, results); r.err != nil || len(r.ms) != 1 {
+		t.Fatalf("expected only the reply to the subscription but got %+v (%v)", r.ms, r.err)
+	}
+
+	r := result(t, sendAsync(ctx, transport, Message{Channel: MetaConnect, ID: "2"}))
+	if r.err != nil {
+		t.Fatalf("unexpected error (%v)", r.err)
+	}
+	if got := ids(r.ms); len(got) != 2 || got[0] != "d1" || got[1] != "d2" {
+		t.Errorf("expected the held deliveries in order but got %v", got)
+	}
+}
+
+func TestWebSocketTransport_ReusesOutstandingConnect(t *testing.T) {
+	transport, conns := newWebSocketPeer(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	results := sendAsync(ctx, transport, Message{Channel: MetaConnect, ID: "1"})
+	conn := accept(t, conns)
+	receive(t, conn)
+	// A delivery returns before the server replies to the /meta/connect
+	reply(t, conn, Message{Channel: "/foo/bar", ID: "d1", Data: []byte(`{}`)})
+	if got := ids(result(t, results).ms); len(got) != 1 || got[0] != "d1" {
+		t.Fatalf("expected only the delivery but got %v", got)
+	}
+
+	// The next /meta/connect waits for the reply to the first rather than
+	// sending another
+	results = sendAsync(ctx, transport, Message{Channel: MetaConnect, ID: "2"})
+	reply(t, conn, Message{Channel: MetaConnect, ID: "1", Successful: true})
+	if got := ids(result(t, results).ms); len(got) != 1 || got[0] != "1" {
+		t.Fatalf("expected the reply to the first /meta/connect but got %v", got)
+	}
+
+	// With nothing outstanding, the next one is sent
+	results = sendAsync(ctx, transport, Message{Channel: MetaConnect, ID: "3"})
+	if got := ids(receive(t, conn)); len(got) != 1 || got[0] != "3" {
+		t.Errorf("expected only the third /meta/connect to be sent but got %v", got)
+	}
+	reply(t, conn, Message{Channel: MetaConnect, ID: "3", Successful: true})
+	result(t, results)
+}
+
+func TestWebSocketTransport_RedialsAfterSocketLoss(t *testing.T) {
+	testCases := []struct {
+		name    string
+		request Message
+	}{
+		{"waiting for a reply", Message{Channel: MetaSubscribe, ID: "1", Subscription: "/foo/bar"}},
+		{"waiting for /meta/connect", Message{Channel: MetaConnect, ID: "1"}},
+	}
+
+	for _, testCase := range testCases {
+		tc := testCase
+		t.Run(tc.name, func(t *testing.T) {
+			transport, conns := newWebSocketPeer(t)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			results := sendAsync(ctx, transport, tc.request)
+			conn := accept(t, conns)
+			receive(t, conn)
+			if err := conn.Close(); err != nil {
+				t.Fatalf("failed to close socket (%v)", err)
+			}
+			if r := result(t, results); !errors.Is(r.err, ErrConnectionClosed) {
+				t.Fatalf("expected ErrConnectionClosed but got %v", r.err)
+			}
+
+			// The next request dials a new socket
+			results = sendAsync(ctx, transport, Message{Channel: MetaSubscribe, ID: "2", Subscription: "/foo/bar"})
+			conn = accept(t, conns)
+			receive(t, conn)
+			reply(t, conn, Message{Channel: MetaSubscribe, ID: "2", Subscription: "/foo/bar", Successful: true})
+			if r := result(t, results); r.err != nil || len(r.ms) != 1 {
+				t.Errorf("expected the reply over the new socket but got %+v (%v)", r.ms, r.err)
+			}
+		})
+	}
+}